@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// runCommand handles "gopanel <subcommand> ..." invocations that operate on
+// the database offline. It returns false if args is not a subcommand, in
+// which case the panel server starts as usual.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "export":
+		cmdExport(args[1:])
+	case "backup":
+		cmdBackup(args[1:])
+	case "restore":
+		cmdRestore(args[1:])
+	case "vacuum", "check":
+		cmdMaintenance(args[0], args[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// dbPathFromFlags resolves the database path from -db, falling back to the
+// db_path in the config file.
+func dbPathFromFlags(cfgPath, dbPath string) string {
+	if dbPath != "" {
+		return dbPath
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		cfg = config.Default()
+	}
+	return cfg.DBPath
+}
+
+func cmdExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "config file path")
+	dbPath := fs.String("db", "", "database path (default: db_path from config)")
+	fromArg := fs.String("from", "", "start time: unix, RFC3339 or 2006-01-02 (default: 24h before -to)")
+	toArg := fs.String("to", "", "end time (default: now)")
+	format := fs.String("format", "csv", "csv or ndjson")
+	out := fs.String("o", "-", "output file, - for stdout")
+	gz := fs.Bool("gzip", false, "gzip output (implied by a .gz output file)")
+	fs.Parse(args)
+
+	to, err := store.ParseTimeArg(*toArg, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	from, err := store.ParseTimeArg(*fromArg, to.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st, err := store.OpenReadOnly(dbPathFromFlags(*cfgPath, *dbPath))
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	defer st.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+		*gz = *gz || strings.HasSuffix(*out, ".gz")
+	}
+	if *gz {
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		w = zw
+	}
+	if err := store.ExportMetrics(st, w, from.Unix(), to.Unix(), *format); err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		os.Exit(1)
+	}
+}
+
+func cmdBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "config file path")
+	dbPath := fs.String("db", "", "database path (default: db_path from config)")
+	out := fs.String("o", "", "backup file to create (default: gopanel-<time>.db)")
+	fs.Parse(args)
+
+	if *out == "" {
+		*out = fmt.Sprintf("gopanel-%s.db", time.Now().Format("20060102-150405"))
+	}
+	st, err := store.OpenSQLite(dbPathFromFlags(*cfgPath, *dbPath))
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	defer st.Close()
+	if err := st.Backup(*out); err != nil {
+		log.Fatalf("backup: %v", err)
+	}
+	fmt.Println("backup written to", *out)
+}
+
+func cmdRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "config file path")
+	dbPath := fs.String("db", "", "database path (default: db_path from config)")
+	in := fs.String("i", "", "backup file to restore from")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Fatal("restore: -i is required")
+	}
+	st, err := store.OpenSQLite(dbPathFromFlags(*cfgPath, *dbPath))
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	defer st.Close()
+	if err := st.Restore(*in); err != nil {
+		log.Fatalf("restore: %v", err)
+	}
+	fmt.Println("restored from", *in)
+}
+
+func cmdMaintenance(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cfgPath := fs.String("config", "config.yaml", "config file path")
+	dbPath := fs.String("db", "", "database path (default: db_path from config)")
+	fs.Parse(args)
+
+	st, err := store.OpenSQLite(dbPathFromFlags(*cfgPath, *dbPath))
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	defer st.Close()
+	if name == "vacuum" {
+		if err := st.Vacuum(); err != nil {
+			log.Fatalf("vacuum: %v", err)
+		}
+		fmt.Println("vacuum done")
+		return
+	}
+	problems, err := st.IntegrityCheck()
+	if err != nil {
+		log.Fatalf("integrity check: %v", err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// maxRestoreSize caps a backup upload; gin spools multipart files past
+// its memory limit to disk, so an unbounded body could fill the disk.
+const maxRestoreSize = 2 << 30
+
+// registerDatabaseRoutes exposes backup/restore and housekeeping for
+// file-backed stores; other backends get 501 Not Implemented.
+func registerDatabaseRoutes(g *gin.RouterGroup, st store.Store) {
+	m, ok := st.(store.Maintainer)
+	if !ok {
+		notSupported := func(c *gin.Context) { c.JSON(501, gin.H{"error": "not supported by this storage backend"}) }
+		g.GET("/db/status", notSupported)
+		g.GET("/db/backup", notSupported)
+		g.POST("/db/restore", notSupported)
+		g.POST("/db/vacuum", notSupported)
+		g.GET("/db/integrity", notSupported)
+		return
+	}
+
+	g.GET("/db/status", func(c *gin.Context) {
+		v, err := m.SchemaVersion()
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		var size int64
+		if fi, err := os.Stat(m.Path()); err == nil { size = fi.Size() }
+		c.JSON(200, gin.H{"schema_version": v, "latest_version": store.LatestSchemaVersion(), "size_bytes": size, "path": m.Path()})
+	})
+
+	// Online backup, streamed back as a download.
+	g.GET("/db/backup", func(c *gin.Context) {
+		dir, err := os.MkdirTemp("", "gopanel-backup-")
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		defer os.RemoveAll(dir)
+		name := fmt.Sprintf("gopanel-%s.db", time.Now().Format("20060102-150405"))
+		tmp := filepath.Join(dir, name)
+		if err := m.Backup(tmp); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.FileAttachment(tmp, name)
+	})
+
+	// Restore from an uploaded backup (multipart field "file"). Every other
+	// store access waits until the copy into the live database is done.
+	g.POST("/db/restore", func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRestoreSize)
+		fh, err := c.FormFile("file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) { c.JSON(413, gin.H{"error": fmt.Sprintf("backup larger than %d bytes", tooLarge.Limit)}); return }
+		if err != nil { c.JSON(400, gin.H{"error": "file required"}); return }
+		dir, err := os.MkdirTemp("", "gopanel-restore-")
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		defer os.RemoveAll(dir)
+		tmp := filepath.Join(dir, "restore.db")
+		if err := c.SaveUploadedFile(fh, tmp); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		if err := m.Restore(tmp); err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		c.JSON(200, gin.H{"ok": true})
+	})
+
+	g.POST("/db/vacuum", func(c *gin.Context) {
+		start := time.Now()
+		if err := m.Vacuum(); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		c.JSON(200, gin.H{"ok": true, "duration_ms": time.Since(start).Milliseconds()})
+	})
+	g.GET("/db/integrity", func(c *gin.Context) {
+		problems, err := m.IntegrityCheck()
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		if problems == nil { problems = []string{} }
+		c.JSON(200, gin.H{"ok": len(problems) == 0, "problems": problems})
+	})
+}
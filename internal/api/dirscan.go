@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// registerDirScanRoutes starts, polls and cancels du-style directory
+// scans. Progress is also pushed over the websocket as "dir_scan".
+func registerDirScanRoutes(g *gin.RouterGroup, ds *store.DirScanner) {
+	g.GET("/disk/scans", func(c *gin.Context) { c.JSON(200, ds.List()) })
+	g.POST("/disk/scan", func(c *gin.Context) {
+		var req struct {
+			Path  string `json:"path"`
+			Depth int    `json:"depth"`
+			Top   int    `json:"top"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Path == "" { c.JSON(400, gin.H{"error": "invalid request"}); return }
+		if req.Depth == 0 { req.Depth = 3 }
+		if req.Top == 0 { req.Top = 20 }
+		scan, err := ds.Start(req.Path, req.Depth, req.Top)
+		if errors.Is(err, store.ErrScanRunning) { c.JSON(409, gin.H{"error": err.Error()}); return }
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		c.JSON(202, scan)
+	})
+	g.GET("/disk/scan", func(c *gin.Context) {
+		scan, ok := ds.Get(c.Query("path"))
+		if !ok { c.JSON(404, gin.H{"error": "no scan for this path"}); return }
+		c.JSON(200, scan)
+	})
+	g.DELETE("/disk/scan", func(c *gin.Context) {
+		if !ds.Cancel(c.Query("path")) { c.JSON(404, gin.H{"error": "no running scan for this path"}); return }
+		c.JSON(200, gin.H{"ok": true})
+	})
+}
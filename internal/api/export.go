@@ -0,0 +1,79 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// exportWriteTimeout is how long an export may wait for the client to
+// accept more data.
+const exportWriteTimeout = time.Minute
+
+// deadlineWriter extends the response write deadline before every write.
+type deadlineWriter struct {
+	rc *http.ResponseController
+	w  io.Writer
+}
+
+func (d deadlineWriter) Write(p []byte) (int, error) {
+	d.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return d.w.Write(p)
+}
+
+// exportMetricsHandler streams history as CSV or NDJSON.
+// Query: from, to (unix / RFC3339 / 2006-01-02, default last 24h),
+// format=csv|ndjson, gzip=1.
+func exportMetricsHandler(st store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		to, err := store.ParseTimeArg(c.Query("to"), now)
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		from, err := store.ParseTimeArg(c.Query("from"), to.Add(-24*time.Hour))
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		if from.After(to) { c.JSON(400, gin.H{"error": "from must be before to"}); return }
+
+		format := c.DefaultQuery("format", "csv")
+		var ctype, ext string
+		switch format {
+		case "csv":
+			ctype, ext = "text/csv; charset=utf-8", "csv"
+		case "ndjson":
+			ctype, ext = "application/x-ndjson", "ndjson"
+		default:
+			c.JSON(400, gin.H{"error": "format must be csv or ndjson"}); return
+		}
+		gz := c.Query("gzip") == "1" || c.Query("gzip") == "true"
+
+		filename := fmt.Sprintf("gopanel-metrics-%s-%s.%s", from.Format("20060102T1504"), to.Format("20060102T1504"), ext)
+		if gz {
+			filename += ".gz"
+			ctype = "application/gzip"
+		}
+		// Large ranges can take longer than the server's WriteTimeout, so
+		// the deadline moves with each write; a client that stops reading
+		// still times out.
+		rc := http.NewResponseController(c.Writer)
+		rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		c.Header("Content-Type", ctype)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(200)
+
+		var w io.Writer = deadlineWriter{rc: rc, w: c.Writer}
+		if gz {
+			zw := gzip.NewWriter(w)
+			defer zw.Close()
+			w = zw
+		}
+		if err := store.ExportMetrics(st, w, from.Unix(), to.Unix(), format); err != nil {
+			log.Printf("metrics export: %v", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// processListHandler serves the live process list. The body stays a plain
+// array for the frontend; the number of matches before paging is sent in
+// X-Total-Count.
+// Query: user, name (regex on name or cmdline), state (comma separated,
+// e.g. zombie), min_cpu, min_mem, sort=cpu|mem|rss|pid|name|start|threads,
+// dir=asc|desc, offset, limit (default 100), group=name|user.
+func processListHandler(c *gin.Context) {
+	q := collector.ProcessQuery{
+		User: c.Query("user"),
+		Name: c.Query("name"),
+		Sort: c.DefaultQuery("sort", "cpu"),
+		Dir:  c.DefaultQuery("dir", "desc"),
+	}
+	if s := c.Query("state"); s != "" {
+		q.States = strings.Split(s, ",")
+	}
+	q.MinCPU, _ = strconv.ParseFloat(c.Query("min_cpu"), 64)
+	q.MinMem, _ = strconv.ParseFloat(c.Query("min_mem"), 64)
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	group := c.Query("group")
+	if group == "" {
+		q.Offset, q.Limit = offset, limit
+	}
+	procs, total, err := collector.QueryProcesses(q)
+	if err != nil { c.JSON(processErrorStatus(err), gin.H{"error": err.Error()}); return }
+	if group == "" {
+		if procs == nil { procs = []collector.ProcessInfo{} }
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		c.JSON(200, procs)
+		return
+	}
+
+	groups, err := collector.GroupProcesses(procs, group, q.Sort)
+	if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+	c.Header("X-Total-Count", strconv.Itoa(len(groups)))
+	groups = page(groups, offset, limit)
+	c.JSON(200, groups)
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	if offset > 0 {
+		items = items[offset:]
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// processHistoryHandler answers "what was running then" from the recorded
+// top-process snapshots.
+// Query: at (a single instant: the snapshot taken at or just before it),
+// or from/to (a window, default last hour) aggregated per process name,
+// sort=cpu|mem, limit (default 20).
+func processHistoryHandler(st store.Store, cfg config.ProcessHistoryConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if at := c.Query("at"); at != "" {
+			t, err := store.ParseTimeArg(at, time.Now())
+			if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+			// tolerate a couple of missed ticks before declaring a gap
+			maxAge := int64(3 * cfg.Interval / time.Second)
+			if maxAge < 180 {
+				maxAge = 180
+			}
+			samples, err := st.ProcessSamples(t.Unix()-maxAge, t.Unix())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			snap := store.SnapshotAt(samples, t.Unix(), maxAge)
+			if snap == nil { snap = []store.ProcessSample{} }
+			var ts int64
+			if len(snap) > 0 {
+				ts = snap[0].Timestamp
+			}
+			c.JSON(200, gin.H{"at": t.Unix(), "timestamp": ts, "processes": snap})
+			return
+		}
+
+		now := time.Now()
+		to, err := store.ParseTimeArg(c.Query("to"), now)
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		from, err := store.ParseTimeArg(c.Query("from"), to.Add(-time.Hour))
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		if from.After(to) { c.JSON(400, gin.H{"error": "from must be before to"}); return }
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		samples, err := st.ProcessSamples(from.Unix(), to.Unix())
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		usage := store.AggregateProcesses(samples, c.DefaultQuery("sort", "cpu"))
+		if limit > 0 && len(usage) > limit {
+			usage = usage[:limit]
+		}
+		c.JSON(200, gin.H{"from": from.Unix(), "to": to.Unix(), "processes": usage})
+	}
+}
+
+// registerProcessControlRoutes adds signal, priority and affinity actions.
+// Every action goes through the collector's refusal list, which protects
+// PID 1 and GoPanel itself.
+func registerProcessControlRoutes(g *gin.RouterGroup) {
+	withPID := func(fn func(c *gin.Context, pid int32) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			pid, err := strconv.ParseInt(c.Param("pid"), 10, 32)
+			if err != nil { c.JSON(400, gin.H{"error": "invalid pid"}); return }
+			if err := fn(c, int32(pid)); err != nil { c.JSON(processErrorStatus(err), gin.H{"error": err.Error()}); return }
+			c.JSON(200, gin.H{"ok": true})
+		}
+	}
+
+	g.POST("/processes/:pid/signal", withPID(func(c *gin.Context, pid int32) error {
+		var req struct { Signal string `json:"signal"` }
+		if err := c.ShouldBindJSON(&req); err != nil { return errBadRequest }
+		sig, err := collector.ParseSignal(req.Signal)
+		if err != nil { return err }
+		return collector.SignalProcess(pid, sig)
+	}))
+	g.POST("/processes/:pid/renice", withPID(func(c *gin.Context, pid int32) error {
+		var req struct { Nice *int `json:"nice"` }
+		if err := c.ShouldBindJSON(&req); err != nil || req.Nice == nil { return errBadRequest }
+		return collector.Renice(pid, *req.Nice)
+	}))
+	g.POST("/processes/:pid/ionice", withPID(func(c *gin.Context, pid int32) error {
+		var req struct {
+			Class string `json:"class"` // none, realtime, best-effort, idle
+			Level int    `json:"level"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil { return errBadRequest }
+		return collector.Ionice(pid, req.Class, req.Level)
+	}))
+	g.POST("/processes/:pid/affinity", withPID(func(c *gin.Context, pid int32) error {
+		var req struct { CPUs string `json:"cpus"` } // e.g. "0-3,6"
+		if err := c.ShouldBindJSON(&req); err != nil { return errBadRequest }
+		return collector.SetAffinity(pid, req.CPUs)
+	}))
+
+	// bulk: {"name": "php-fpm", "signal": "HUP"}
+	g.POST("/processes/signal", func(c *gin.Context) {
+		var req struct {
+			Name   string `json:"name"`
+			Signal string `json:"signal"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" { c.JSON(400, gin.H{"error": "invalid request"}); return }
+		sig, err := collector.ParseSignal(req.Signal)
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		results, err := collector.SignalByName(req.Name, sig)
+		if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+		c.JSON(200, gin.H{"matched": len(results), "results": results})
+	})
+}
+
+var errBadRequest = errors.New("invalid request")
+
+// processErrorStatus maps process control errors to HTTP status codes.
+func processErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest), errors.Is(err, collector.ErrInvalidArgument), errors.Is(err, syscall.EINVAL):
+		return 400
+	case errors.Is(err, collector.ErrProtected), errors.Is(err, syscall.EPERM):
+		return 403
+	case errors.Is(err, syscall.ESRCH):
+		return 404
+	}
+	return 500
+}
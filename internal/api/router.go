@@ -1,7 +1,6 @@
 package api
 
 import (
-	"database/sql"
 	"embed"
 	"io/fs"
 	"strconv"
@@ -25,7 +24,7 @@ var AppVersion = "dev"
 
 func SetConfigPath(p string) { configPath = p }
 
-func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *gin.Engine {
+func SetupRouter(cfg *config.Config, st store.Store, det *store.Detector, wd *store.Watchdog, ds *store.DirScanner, hub *ws.Hub, webFS embed.FS) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(gin.Recovery())
@@ -33,6 +32,7 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 		AllowAllOrigins: true,
 		AllowMethods:    []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:    []string{"Authorization", "Content-Type"},
+		ExposeHeaders:   []string{"X-Total-Count"},
 	}))
 
 	api := r.Group("/api")
@@ -47,24 +47,69 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 		auth.GET("/cpu",         func(c *gin.Context) { c.JSON(200, collector.GetCPUStats()) })
 		auth.GET("/memory",      func(c *gin.Context) { c.JSON(200, collector.GetMemoryStats()) })
 		auth.GET("/disk",        func(c *gin.Context) { c.JSON(200, collector.GetDiskStats()) })
+		auth.GET("/storage",     func(c *gin.Context) { c.JSON(200, collector.GetStorageStatus()) })
+		auth.GET("/disk/io",     func(c *gin.Context) { c.JSON(200, collector.GetDiskIO()) })
+		auth.GET("/disk/io/history", func(c *gin.Context) {
+			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+			now := time.Now()
+			data, err := st.DiskIOHistory(c.Query("device"), now.Add(-time.Duration(hours)*time.Hour).Unix(), now.Unix())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.DiskIOPoint{} }
+			c.JSON(200, data)
+		})
 		auth.GET("/network",     func(c *gin.Context) { c.JSON(200, collector.GetNetworkStats()) })
+		auth.GET("/network/listeners", func(c *gin.Context) {
+			data, err := collector.GetListeners()
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []collector.Listener{} }
+			if cs, ok := cache.GetDockerContainers(); ok { collector.MapContainers(data, cs) }
+			c.JSON(200, data)
+		})
+		auth.GET("/network/connections", func(c *gin.Context) {
+			pid, _ := strconv.Atoi(c.Query("pid"))
+			top, _ := strconv.Atoi(c.DefaultQuery("top", "20"))
+			data, err := collector.GetConnectionSummary(int32(pid), top)
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			c.JSON(200, data)
+		})
+		auth.GET("/network/connections/history", func(c *gin.Context) {
+			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+			now := time.Now()
+			data, err := st.TCPStateHistory(now.Add(-time.Duration(hours)*time.Hour).Unix(), now.Unix())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.TCPStatePoint{} }
+			c.JSON(200, data)
+		})
 		auth.GET("/temperature", func(c *gin.Context) { c.JSON(200, collector.GetTemperatures()) })
 		auth.GET("/crontab",     func(c *gin.Context) { c.JSON(200, collector.GetCrontabs()) })
 
-		auth.GET("/processes", func(c *gin.Context) {
-			sortBy := c.DefaultQuery("sort", "cpu")
-			sortDir := c.DefaultQuery("dir", "desc")
-			limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
-			procs, err := collector.GetProcesses(sortBy, sortDir, limit)
+		auth.GET("/processes", processListHandler)
+		auth.GET("/processes/history", processHistoryHandler(st, cfg.History.Processes))
+		auth.GET("/processes/tree", func(c *gin.Context) {
+			tree, err := collector.GetProcessTree()
 			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
-			c.JSON(200, procs)
+			if tree == nil { tree = []*collector.ProcessNode{} }
+			c.JSON(200, tree)
+		})
+		auth.GET("/processes/:pid", func(c *gin.Context) {
+			pid, err := strconv.ParseInt(c.Param("pid"), 10, 32)
+			if err != nil { c.JSON(400, gin.H{"error": "invalid pid"}); return }
+			d, err := collector.GetProcessDetail(int32(pid))
+			if err != nil { c.JSON(404, gin.H{"error": err.Error()}); return }
+			if d.Container != "" {
+				if name := containerName(d.Container); name != d.Container { d.ContainerName = name }
+			}
+			c.JSON(200, d)
 		})
 		auth.DELETE("/processes/:pid", func(c *gin.Context) {
 			pid, err := strconv.ParseInt(c.Param("pid"), 10, 32)
 			if err != nil { c.JSON(400, gin.H{"error": "invalid pid"}); return }
-			if err := collector.KillProcess(int32(pid)); err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if err := collector.KillProcess(int32(pid)); err != nil { c.JSON(processErrorStatus(err), gin.H{"error": err.Error()}); return }
 			c.JSON(200, gin.H{"ok": true})
 		})
+		registerProcessControlRoutes(auth)
+		registerWatchdogRoutes(auth, wd)
+		registerDirScanRoutes(auth, ds)
 
 		auth.GET("/docker/containers", func(c *gin.Context) {
 			data, ok := cache.GetDockerContainers()
@@ -96,6 +141,22 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 			if err != nil { c.JSON(500, gin.H{"error": err.Error(), "log": log}); return }
 			c.JSON(200, gin.H{"log": log})
 		})
+		auth.GET("/docker/history", func(c *gin.Context) {
+			data, err := st.ContainerSeries()
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.ContainerSeries{} }
+			c.JSON(200, data)
+		})
+		auth.GET("/docker/containers/:id/history", func(c *gin.Context) {
+			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+			name := containerName(c.Param("id"))
+			now := time.Now()
+			data, err := st.ContainerHistory(name, now.Add(-time.Duration(hours)*time.Hour).Unix(), now.Unix())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.ContainerPoint{} }
+			store.ContainerRates(data)
+			c.JSON(200, gin.H{"name": name, "points": data})
+		})
 		auth.GET("/docker/compose/file", func(c *gin.Context) {
 			path := c.Query("path")
 			if path == "" { c.JSON(400, gin.H{"error": "path required"}); return }
@@ -139,6 +200,19 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
 			c.JSON(200, gin.H{"logs": logs})
 		})
+		auth.GET("/services/:unit/history", func(c *gin.Context) {
+			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+			now := time.Now()
+			data, err := st.ServiceHistory(c.Param("unit"), now.Add(-time.Duration(hours)*time.Hour).Unix(), now.Unix())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.ServicePoint{} }
+			store.ServiceRates(data)
+			c.JSON(200, gin.H{
+				"unit":                         c.Param("unit"),
+				"points":                       data,
+				"memory_growth_bytes_per_hour": store.MemoryGrowth(data),
+			})
+		})
 		auth.GET("/services/:unit/file", func(c *gin.Context) {
 			content, path, err := collector.ReadServiceFile(c.Param("unit"))
 			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
@@ -153,11 +227,57 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 
 		auth.GET("/metrics/history", func(c *gin.Context) {
 			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
-			data, err := store.GetMetricsHistory(db, hours)
+			data, err := store.MetricsHistory(st, hours)
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.MetricPoint{} }
+			c.JSON(200, data)
+		})
+		auth.GET("/metrics/export", exportMetricsHandler(st))
+		auth.GET("/traffic/daily", func(c *gin.Context) {
+			days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+			data, err := store.TrafficDaily(st, c.Query("iface"), days, time.Now())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			c.JSON(200, data)
+		})
+		auth.GET("/traffic/monthly", func(c *gin.Context) {
+			months, _ := strconv.Atoi(c.DefaultQuery("months", "12"))
+			data, err := store.TrafficMonthly(st, c.Query("iface"), months, cfg.Traffic.CycleStartDay, time.Now())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			c.JSON(200, data)
+		})
+		auth.GET("/traffic/cycle", func(c *gin.Context) {
+			data, err := store.CurrentTrafficCycle(st, cfg.Traffic, time.Now())
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			c.JSON(200, data)
+		})
+		auth.GET("/anomaly", func(c *gin.Context) {
+			scores, err := det.Latest()
 			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
-			if data == nil { data = []map[string]interface{}{} }
+			c.JSON(200, scores)
+		})
+		auth.GET("/anomaly/baseline", func(c *gin.Context) {
+			buckets, builtAt, err := det.Baseline(c.DefaultQuery("metric", "cpu"))
+			if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+			c.JSON(200, gin.H{"built_at": builtAt.Unix(), "buckets": buckets})
+		})
+		auth.GET("/store/stats", func(c *gin.Context) { c.JSON(200, st.Stats()) })
+		auth.GET("/alerts", func(c *gin.Context) {
+			limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+			data, err := st.Alerts(limit)
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.Alert{} }
 			c.JSON(200, data)
 		})
+		auth.GET("/events/kernel", func(c *gin.Context) {
+			hours, _ := strconv.Atoi(c.DefaultQuery("hours", "168"))
+			limit, _ := strconv.Atoi(c.DefaultQuery("limit", "200"))
+			now := time.Now()
+			data, err := st.KernelEvents(now.Add(-time.Duration(hours)*time.Hour).Unix(), now.Unix(), c.Query("type"), limit)
+			if err != nil { c.JSON(500, gin.H{"error": err.Error()}); return }
+			if data == nil { data = []store.KernelEvent{} }
+			c.JSON(200, data)
+		})
+		registerDatabaseRoutes(auth, st)
 
 		// Settings: change username/password
 		auth.POST("/settings/credentials", func(c *gin.Context) {
@@ -171,9 +291,10 @@ func SetupRouter(cfg *config.Config, db *sql.DB, hub *ws.Hub, webFS embed.FS) *g
 			if req.Username != cfg.Username || req.Password != cfg.Password {
 				c.JSON(401, gin.H{"error": "current credentials incorrect"}); return
 			}
-			if req.NewUsername != "" { cfg.Username = req.NewUsername }
-			if req.NewPassword != "" { cfg.Password = req.NewPassword }
-			if configPath != "" { cfg.Save(configPath) }
+			cfg.Update(configPath, func(cfg *config.Config) {
+				if req.NewUsername != "" { cfg.Username = req.NewUsername }
+				if req.NewPassword != "" { cfg.Password = req.NewPassword }
+			})
 			c.JSON(200, gin.H{"ok": true})
 		})
 	}
@@ -215,6 +336,18 @@ func loginHandler(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// containerName maps a container ID (or ID prefix) to its name, since
+// history is keyed by name; anything else is taken to be a name already.
+func containerName(idOrName string) string {
+	if data, ok := cache.GetDockerContainers(); ok {
+		for _, ct := range data {
+			if ct.Name == idOrName { return idOrName }
+			if len(idOrName) >= 4 && (strings.HasPrefix(ct.ID, idOrName) || strings.HasPrefix(idOrName, ct.ID)) { return ct.Name }
+		}
+	}
+	return idOrName
+}
+
 func mimeType(path string) string {
 	switch {
 	case strings.HasSuffix(path, ".html"): return "text/html; charset=utf-8"
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/store"
+)
+
+// registerWatchdogRoutes manages the process watch list. Changes are
+// written back to the config file like the credentials setting.
+func registerWatchdogRoutes(g *gin.RouterGroup, wd *store.Watchdog) {
+	save := func(c *gin.Context) bool {
+		if configPath == "" { return true }
+		if err := wd.Save(configPath); err != nil { c.JSON(500, gin.H{"error": "saved in memory only: " + err.Error()}); return false }
+		return true
+	}
+
+	g.GET("/watchdog", func(c *gin.Context) { c.JSON(200, wd.Status()) })
+	g.POST("/watchdog", func(c *gin.Context) {
+		var w config.ProcessWatch
+		if err := c.ShouldBindJSON(&w); err != nil { c.JSON(400, gin.H{"error": "invalid request"}); return }
+		err := wd.Put(w)
+		if errors.Is(err, store.ErrCommandNotAllowed) { c.JSON(403, gin.H{"error": err.Error()}); return }
+		if err != nil { c.JSON(400, gin.H{"error": err.Error()}); return }
+		if !save(c) { return }
+		go wd.Check()
+		c.JSON(200, gin.H{"ok": true})
+	})
+	g.DELETE("/watchdog/:name", func(c *gin.Context) {
+		if !wd.Delete(c.Param("name")) { c.JSON(404, gin.H{"error": "watch not found"}); return }
+		if !save(c) { return }
+		c.JSON(200, gin.H{"ok": true})
+	})
+}
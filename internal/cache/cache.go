@@ -15,8 +15,28 @@ var (
 	servicesMu        sync.RWMutex
 	servicesData      []collector.SystemdService
 	servicesUpdatedAt time.Time
+
+	hooksMu       sync.Mutex
+	dockerHooks   []func([]collector.Container)
+	servicesHooks []func([]collector.SystemdService)
 )
 
+// OnDockerRefresh registers fn to be called with every fresh container
+// list, e.g. to record history. Register before Start.
+func OnDockerRefresh(fn func([]collector.Container)) {
+	hooksMu.Lock()
+	dockerHooks = append(dockerHooks, fn)
+	hooksMu.Unlock()
+}
+
+// OnServicesRefresh registers fn to be called with every fresh service
+// list. Register before Start.
+func OnServicesRefresh(fn func([]collector.SystemdService)) {
+	hooksMu.Lock()
+	servicesHooks = append(servicesHooks, fn)
+	hooksMu.Unlock()
+}
+
 // Start 启动后台定时刷新
 func Start(interval time.Duration) {
 	refreshDocker()
@@ -37,6 +57,13 @@ func refreshDocker() {
 	dockerData = data
 	dockerUpdatedAt = time.Now()
 	dockerMu.Unlock()
+
+	hooksMu.Lock()
+	hooks := dockerHooks
+	hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn(data)
+	}
 }
 
 func refreshServices() {
@@ -46,6 +73,13 @@ func refreshServices() {
 	servicesData = data
 	servicesUpdatedAt = time.Now()
 	servicesMu.Unlock()
+
+	hooksMu.Lock()
+	hooks := servicesHooks
+	hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn(data)
+	}
 }
 
 func GetDockerContainers() ([]collector.Container, bool) {
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// TCPStates lists the kernel's TCP states in /proc/net/tcp order
+// (state 0x01 first).
+var TCPStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// ConnCount is a connection count for a remote IP or a local port.
+type ConnCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Connection is one socket of the connection table.
+type Connection struct {
+	ProcessSocket
+	PID int32 `json:"pid"`
+}
+
+// ConnectionSummary breaks the connection table down so that a large
+// count can be attributed: by state, by remote peer and by local service.
+type ConnectionSummary struct {
+	Total       int            `json:"total"`
+	States      map[string]int `json:"states"`
+	TopRemotes  []ConnCount    `json:"top_remotes"`
+	LocalPorts  []ConnCount    `json:"local_ports"` // inbound, per listening port
+	Connections []Connection   `json:"connections"` // only when filtered by PID
+}
+
+const maxListedConnections = 1000
+
+// GetConnectionSummary summarises every inet socket, or those of pid when
+// pid > 0. top bounds TopRemotes and LocalPorts.
+func GetConnectionSummary(pid int32, top int) (ConnectionSummary, error) {
+	var conns []psnet.ConnectionStat
+	var err error
+	if pid > 0 {
+		conns, err = psnet.ConnectionsPid("inet", pid)
+	} else {
+		conns, err = psnet.Connections("inet")
+	}
+	if err != nil {
+		return ConnectionSummary{}, err
+	}
+
+	listening := map[string]bool{}
+	for _, c := range conns {
+		if c.Status == "LISTEN" {
+			listening[socketProto(c.Family, c.Type)+"/"+strconv.Itoa(int(c.Laddr.Port))] = true
+		}
+	}
+	s := ConnectionSummary{States: map[string]int{}, Connections: []Connection{}}
+	remotes := map[string]int{}
+	ports := map[string]int{}
+	for _, c := range conns {
+		proto := socketProto(c.Family, c.Type)
+		state := c.Status
+		if state == "" || state == "NONE" {
+			state = "UDP"
+		}
+		s.Total++
+		s.States[state]++
+		if c.Raddr.IP != "" && c.Raddr.Port != 0 {
+			remotes[c.Raddr.IP]++
+			if port := proto + "/" + strconv.Itoa(int(c.Laddr.Port)); listening[port] && state != "LISTEN" {
+				ports[port]++
+			}
+		}
+		if pid > 0 && len(s.Connections) < maxListedConnections {
+			s.Connections = append(s.Connections, Connection{PID: c.Pid, ProcessSocket: ProcessSocket{
+				Proto:  proto,
+				Local:  fmt.Sprintf("%s:%d", c.Laddr.IP, c.Laddr.Port),
+				Remote: fmt.Sprintf("%s:%d", c.Raddr.IP, c.Raddr.Port),
+				State:  state,
+			}})
+		}
+	}
+	s.TopRemotes = topCounts(remotes, top)
+	s.LocalPorts = topCounts(ports, top)
+	return s, nil
+}
+
+func topCounts(m map[string]int, top int) []ConnCount {
+	out := make([]ConnCount, 0, len(m))
+	for k, n := range m {
+		out = append(out, ConnCount{Key: k, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+// GetTCPStateCounts counts IPv4 and IPv6 TCP sockets per state straight
+// from /proc/net, which is far cheaper than resolving owners and cheap
+// enough to record periodically.
+func GetTCPStateCounts() (map[string]int, error) {
+	counts := map[string]int{}
+	read := 0
+	for _, f := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		read++
+		countTCPStates(string(data), counts)
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("no /proc/net/tcp")
+	}
+	return counts, nil
+}
+
+// countTCPStates adds the states of a /proc/net/tcp table to counts. The
+// state is the 4th column, in hex.
+func countTCPStates(content string, counts map[string]int) {
+	for i, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 4 {
+			continue
+		}
+		st, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil || st == 0 || int(st) > len(TCPStates) {
+			continue
+		}
+		counts[TCPStates[st-1]]++
+	}
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// CPUBreakdown splits CPU time over an interval into percentages of the
+// whole. Guest time is already counted in User by the kernel and is shown
+// separately for information only.
+type CPUBreakdown struct {
+	User    float64 `json:"user"`
+	System  float64 `json:"system"`
+	Nice    float64 `json:"nice"`
+	Idle    float64 `json:"idle"`
+	Iowait  float64 `json:"iowait"`
+	Irq     float64 `json:"irq"`
+	Softirq float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+	Guest   float64 `json:"guest"`
+}
+
+// Usage is the busy share: everything except idle and iowait.
+func (b CPUBreakdown) Usage() float64 {
+	if u := 100 - b.Idle - b.Iowait; u > 0 {
+		return u
+	}
+	return 0
+}
+
+// CPUBreakdownOf computes the breakdown between two cumulative samples.
+// A zero prev gives the average since boot.
+func CPUBreakdownOf(prev, cur cpu.TimesStat) CPUBreakdown {
+	d := func(a, b float64) float64 {
+		if b < a { // counters reset (CPU hot-plugged)
+			return 0
+		}
+		return b - a
+	}
+	user, nice := d(prev.User, cur.User), d(prev.Nice, cur.Nice)
+	system, idle, iowait := d(prev.System, cur.System), d(prev.Idle, cur.Idle), d(prev.Iowait, cur.Iowait)
+	irq, softirq, steal := d(prev.Irq, cur.Irq), d(prev.Softirq, cur.Softirq), d(prev.Steal, cur.Steal)
+	guest := d(prev.Guest, cur.Guest) + d(prev.GuestNice, cur.GuestNice)
+	total := user + nice + system + idle + iowait + irq + softirq + steal
+	if total <= 0 {
+		return CPUBreakdown{Idle: 100}
+	}
+	pct := func(v float64) float64 { return v / total * 100 }
+	return CPUBreakdown{
+		User: pct(user), System: pct(system), Nice: pct(nice), Idle: pct(idle), Iowait: pct(iowait),
+		Irq: pct(irq), Softirq: pct(softirq), Steal: pct(steal), Guest: pct(guest),
+	}
+}
+
+// CPUSampler turns cumulative CPU times into breakdowns between two calls
+// of Sample, so no call has to sleep to measure an interval.
+type CPUSampler struct {
+	mu      sync.Mutex
+	prev    []cpu.TimesStat // [0] is the total, then one per core
+	prevAt  time.Time
+	overall CPUBreakdown
+	perCore []CPUBreakdown
+}
+
+var defaultCPU = &CPUSampler{}
+
+// the first Sample is the average since boot, not a current reading, so
+// the shared sampler takes it before any caller can see it
+func init() { defaultCPU.Sample() }
+
+// Sample returns the overall and per-core breakdown since the previous
+// Sample, or since boot on the first call. Calls less than a second apart
+// return the previous result rather than noisy percentages.
+func (s *CPUSampler) Sample() (CPUBreakdown, []CPUBreakdown) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.prev != nil && now.Sub(s.prevAt) < time.Second {
+		return s.overall, s.perCore
+	}
+	total, err := cpu.Times(false)
+	if err != nil || len(total) == 0 {
+		return s.overall, s.perCore
+	}
+	cores, _ := cpu.Times(true)
+	cur := append(total[:1:1], cores...)
+
+	s.overall = CPUBreakdownOf(s.prevTimes(0), cur[0])
+	s.perCore = make([]CPUBreakdown, len(cores))
+	for i := range cores {
+		s.perCore[i] = CPUBreakdownOf(s.prevTimes(i+1), cur[i+1])
+	}
+	s.prev, s.prevAt = cur, now
+	return s.overall, s.perCore
+}
+
+func (s *CPUSampler) prevTimes(i int) cpu.TimesStat {
+	if i < len(s.prev) {
+		return s.prev[i]
+	}
+	return cpu.TimesStat{}
+}
@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// DirEntry is a file or directory with its disk usage. Directories keep
+// their largest children down to the scan's depth limit.
+type DirEntry struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	Size     uint64      `json:"size"` // allocated bytes, like du
+	IsDir    bool        `json:"is_dir"`
+	Files    int         `json:"files"` // below this directory, all levels
+	Dirs     int         `json:"dirs"`
+	Children []*DirEntry `json:"children,omitempty"`
+	Omitted  int         `json:"omitted,omitempty"` // children left out of the top N
+}
+
+// ScanOptions bounds a directory scan.
+type ScanOptions struct {
+	Depth      int // levels of children kept in the result
+	TopN       int // children kept per directory
+	MaxEntries int // stop after this many files and directories
+}
+
+// ScanProgress is reported periodically while a scan runs.
+type ScanProgress struct {
+	Entries   int    `json:"entries"`
+	Bytes     uint64 `json:"bytes"`
+	Errors    int    `json:"errors"` // unreadable entries, skipped
+	Current   string `json:"current"`
+	Truncated bool   `json:"truncated"` // MaxEntries was reached
+}
+
+// ErrScanLimit is returned with the partial result when MaxEntries is hit.
+var ErrScanLimit = errors.New("entry limit reached")
+
+type dirScan struct {
+	ctx      context.Context
+	opts     ScanOptions
+	dev      uint64
+	inodes   map[[2]uint64]bool // hard links are counted once
+	progress ScanProgress
+	report   func(ScanProgress)
+	lastSent time.Time
+}
+
+// ScanDir walks root like du -x: it stays on root's filesystem, does not
+// follow symlinks and counts hard-linked files once. report, if not nil,
+// is called about twice a second. On cancellation or when MaxEntries is
+// reached the partial tree is returned together with the error.
+func ScanDir(ctx context.Context, root string, opts ScanOptions, report func(ScanProgress)) (*DirEntry, ScanProgress, error) {
+	if opts.Depth <= 0 {
+		opts.Depth = 3
+	}
+	if opts.TopN <= 0 {
+		opts.TopN = 20
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(root, &st); err != nil {
+		return nil, ScanProgress{}, err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return nil, ScanProgress{}, &os.PathError{Op: "scan", Path: root, Err: syscall.ENOTDIR}
+	}
+	s := &dirScan{ctx: ctx, opts: opts, dev: uint64(st.Dev), inodes: map[[2]uint64]bool{}, report: report}
+	entry, err := s.scan(root, filepath.Base(root), &st, 0)
+	if err == nil && s.progress.Truncated {
+		err = ErrScanLimit
+	}
+	if report != nil {
+		report(s.progress)
+	}
+	return entry, s.progress, err
+}
+
+func (s *dirScan) scan(path, name string, st *syscall.Stat_t, depth int) (*DirEntry, error) {
+	e := &DirEntry{Name: name, Path: path, IsDir: true, Size: uint64(st.Blocks) * 512}
+	s.count(path, e.Size)
+	names, err := readDirNames(path)
+	if err != nil {
+		s.progress.Errors++
+		return e, nil
+	}
+	var children []*DirEntry
+	for _, n := range names {
+		if err := s.ctx.Err(); err != nil {
+			return s.finish(e, children, depth), err
+		}
+		if s.opts.MaxEntries > 0 && s.progress.Entries >= s.opts.MaxEntries {
+			s.progress.Truncated = true
+			break
+		}
+		p := filepath.Join(path, n)
+		var cst syscall.Stat_t
+		if err := syscall.Lstat(p, &cst); err != nil {
+			s.progress.Errors++
+			continue
+		}
+		if uint64(cst.Dev) != s.dev {
+			continue // another filesystem mounted here
+		}
+		if cst.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			child, err := s.scan(p, n, &cst, depth+1)
+			e.Size += child.Size
+			e.Files += child.Files
+			e.Dirs += child.Dirs + 1
+			children = append(children, child)
+			if err != nil {
+				return s.finish(e, children, depth), err
+			}
+			continue
+		}
+		if cst.Nlink > 1 {
+			key := [2]uint64{uint64(cst.Dev), uint64(cst.Ino)}
+			if s.inodes[key] {
+				continue
+			}
+			s.inodes[key] = true
+		}
+		size := uint64(cst.Blocks) * 512
+		s.count(p, size)
+		e.Size += size
+		e.Files++
+		if depth < s.opts.Depth {
+			children = append(children, &DirEntry{Name: n, Path: p, Size: size})
+		}
+	}
+	return s.finish(e, children, depth), nil
+}
+
+// finish keeps the TopN largest children, or none below the depth limit.
+func (s *dirScan) finish(e *DirEntry, children []*DirEntry, depth int) *DirEntry {
+	if depth >= s.opts.Depth {
+		return e
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Size > children[j].Size })
+	if len(children) > s.opts.TopN {
+		e.Omitted = len(children) - s.opts.TopN
+		children = children[:s.opts.TopN]
+	}
+	e.Children = children
+	return e
+}
+
+func (s *dirScan) count(path string, size uint64) {
+	s.progress.Entries++
+	s.progress.Bytes += size
+	if s.report != nil && time.Since(s.lastSent) >= 500*time.Millisecond {
+		s.lastSent = time.Now()
+		s.progress.Current = path
+		s.report(s.progress)
+	}
+}
+
+// readDirNames lists a directory without sorting or stat'ing its entries.
+func readDirNames(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
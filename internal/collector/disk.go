@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// MountInfo is one line of /proc/<pid>/mountinfo.
+type MountInfo struct {
+	ID           int
+	Parent       int
+	Major, Minor int
+	Root         string // path inside the filesystem; not "/" for bind mounts of a subdirectory
+	Mountpoint   string
+	Options      string // per-mount options: rw/ro, nosuid, relatime…
+	FSType       string
+	Source       string
+	SuperOptions string // filesystem options: errors=remount-ro, upperdir=…
+}
+
+// ParseMountinfo parses /proc/self/mountinfo content:
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue".
+func ParseMountinfo(content string) []MountInfo {
+	var out []MountInfo
+	for _, line := range strings.Split(content, "\n") {
+		pre, post, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(pre)
+		g := strings.Fields(post)
+		if len(f) < 6 || len(g) < 2 {
+			continue
+		}
+		m := MountInfo{Root: unescapeMount(f[3]), Mountpoint: unescapeMount(f[4]), Options: f[5], FSType: g[0], Source: unescapeMount(g[1])}
+		m.ID, _ = strconv.Atoi(f[0])
+		m.Parent, _ = strconv.Atoi(f[1])
+		maj, min, _ := strings.Cut(f[2], ":")
+		m.Major, _ = strconv.Atoi(maj)
+		m.Minor, _ = strconv.Atoi(min)
+		if len(g) > 2 {
+			m.SuperOptions = g[2]
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// unescapeMount decodes the octal escapes (\040 for space, \011, \012,
+// \134) the kernel uses in mount paths.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func hasOption(opts, name string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+func optionValue(opts, name string) string {
+	for _, o := range strings.Split(opts, ",") {
+		if k, v, ok := strings.Cut(o, "="); ok && k == name {
+			return v
+		}
+	}
+	return ""
+}
+
+// diskFilesystems returns the filesystem types backed by a block device
+// (those not flagged nodev in /proc/filesystems), plus zfs and overlay.
+func diskFilesystems() map[string]bool {
+	types := map[string]bool{"zfs": true, "overlay": true}
+	data, err := os.ReadFile("/proc/filesystems")
+	if err != nil {
+		for _, t := range []string{"ext2", "ext3", "ext4", "xfs", "btrfs", "vfat", "exfat", "f2fs", "fuseblk"} {
+			types[t] = true
+		}
+		return types
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 1 {
+			types[f[0]] = true
+		}
+	}
+	return types
+}
+
+// fsKey identifies the filesystem behind a mount, so bind mounts and
+// several btrfs subvolumes of one device are counted once. btrfs gives
+// each subvolume its own anonymous device number, hence the source.
+func fsKey(m MountInfo) string {
+	if m.Major == 0 && strings.HasPrefix(m.Source, "/dev/") {
+		return m.FSType + ":" + m.Source
+	}
+	return strconv.Itoa(m.Major) + ":" + strconv.Itoa(m.Minor)
+}
+
+// BlockDevice describes the kernel block device behind a filesystem.
+type BlockDevice struct {
+	Name  string   // kernel name: sda1, nvme0n1p2, dm-0, md0
+	Disks []string // whole disks it lives on: sda for sda1, the members of a dm or md device
+	Model string
+}
+
+// resolveBlockDevice follows /sys/dev/block/MAJ:MIN to the kernel device,
+// then partitions up to their disk and dm/md devices down to their slaves.
+func resolveBlockDevice(major, minor int) (BlockDevice, bool) {
+	path, err := filepath.EvalSymlinks("/sys/dev/block/" + strconv.Itoa(major) + ":" + strconv.Itoa(minor))
+	if err != nil {
+		return BlockDevice{}, false
+	}
+	bd := BlockDevice{Name: filepath.Base(path)}
+	seen := map[string]bool{}
+	var models []string
+	var walk func(path string, depth int)
+	walk = func(path string, depth int) {
+		if _, err := os.Stat(filepath.Join(path, "partition")); err == nil {
+			path = filepath.Dir(path)
+		}
+		if slaves, _ := os.ReadDir(filepath.Join(path, "slaves")); len(slaves) > 0 && depth < 8 {
+			for _, s := range slaves {
+				if p, err := filepath.EvalSymlinks(filepath.Join(path, "slaves", s.Name())); err == nil {
+					walk(p, depth+1)
+				}
+			}
+			return
+		}
+		name := filepath.Base(path)
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		bd.Disks = append(bd.Disks, name)
+		if model := readSysString(filepath.Join(path, "device", "model")); model != "" {
+			models = append(models, model)
+		}
+	}
+	walk(path, 0)
+	sort.Strings(bd.Disks)
+	sort.Strings(models)
+	bd.Model = strings.Join(uniqueStrings(models), ", ")
+	return bd, true
+}
+
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func uniqueStrings(in []string) []string {
+	var out []string
+	for i, s := range in {
+		if i == 0 || s != in[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func GetDiskStats() DiskStats {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return DiskStats{Partitions: []DiskPartition{}}
+	}
+	mounts := ParseMountinfo(string(data))
+	types := diskFilesystems()
+
+	// the mount that is last on a mountpoint hides the earlier ones
+	visible := map[string]int{}
+	for i, m := range mounts {
+		visible[m.Mountpoint] = i
+	}
+	var candidates []MountInfo
+	for i, m := range mounts {
+		if visible[m.Mountpoint] == i && types[m.FSType] && strings.HasPrefix(m.Mountpoint, "/") {
+			candidates = append(candidates, m)
+		}
+	}
+	// per filesystem, the mount of its root with the shortest path is the
+	// primary one and the others are reported as its bind mounts
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.Root == "/") != (b.Root == "/") {
+			return a.Root == "/"
+		}
+		return len(a.Mountpoint) < len(b.Mountpoint)
+	})
+
+	ios, _ := disk.IOCounters()
+	byKey := map[string]int{}
+	var partitions []DiskPartition
+	var overlays []MountInfo
+	for _, m := range candidates {
+		if m.FSType == "overlay" {
+			overlays = append(overlays, m)
+			continue
+		}
+		if i, ok := byKey[fsKey(m)]; ok {
+			partitions[i].BindMounts = append(partitions[i].BindMounts, m.Mountpoint)
+			continue
+		}
+		dp, ok := diskPartition(m, ios)
+		if !ok {
+			continue
+		}
+		byKey[fsKey(m)] = len(partitions)
+		partitions = append(partitions, dp)
+	}
+	// an overlay (a container's root) reports the usage of the filesystem
+	// holding its upper layer; it only counts on its own when that
+	// filesystem is not listed, e.g. inside a container
+	for _, m := range overlays {
+		if i := containingPartition(partitions, optionValue(m.SuperOptions, "upperdir")); i >= 0 {
+			partitions[i].BindMounts = append(partitions[i].BindMounts, m.Mountpoint)
+			continue
+		}
+		if dp, ok := diskPartition(m, ios); ok {
+			partitions = append(partitions, dp)
+		}
+	}
+	sort.SliceStable(partitions, func(i, j int) bool { return partitions[i].Mountpoint < partitions[j].Mountpoint })
+	if partitions == nil {
+		partitions = []DiskPartition{}
+	}
+	return DiskStats{Partitions: partitions}
+}
+
+func diskPartition(m MountInfo, ios map[string]disk.IOCountersStat) (DiskPartition, bool) {
+	u, err := disk.Usage(m.Mountpoint)
+	if err != nil || u.Total == 0 {
+		return DiskPartition{}, false
+	}
+	dp := DiskPartition{
+		Device: m.Source, Mountpoint: m.Mountpoint,
+		Fstype: m.FSType, Total: u.Total,
+		Used: u.Used, Free: u.Free, UsedPercent: u.UsedPercent,
+		InodesTotal: u.InodesTotal, InodesUsed: u.InodesUsed, InodesFree: u.InodesFree,
+		InodesUsedPercent: u.InodesUsedPercent,
+		Options:           m.Options,
+		FsOptions:         m.SuperOptions,
+		ReadOnly:          hasOption(m.Options, "ro") || hasOption(m.SuperOptions, "ro"),
+		BindMounts:        []string{},
+		Disks:             []string{},
+	}
+	devName := m.Source[strings.LastIndex(m.Source, "/")+1:]
+	if bd, ok := resolveBlockDevice(m.Major, m.Minor); ok {
+		devName = bd.Name
+		dp.KernelName, dp.Model = bd.Name, bd.Model
+		dp.Disks = bd.Disks
+	}
+	if io, ok := ios[devName]; ok {
+		dp.ReadBytes = io.ReadBytes
+		dp.WriteBytes = io.WriteBytes
+	}
+	return dp, true
+}
+
+// containingPartition returns the index of the partition with the longest
+// mountpoint that contains path, or -1.
+func containingPartition(partitions []DiskPartition, path string) int {
+	best, bestLen := -1, -1
+	if path == "" {
+		return best
+	}
+	for i, p := range partitions {
+		mp := strings.TrimSuffix(p.Mountpoint, "/")
+		if (path == mp || strings.HasPrefix(path, mp+"/")) && len(mp) > bestLen {
+			best, bestLen = i, len(mp)
+		}
+	}
+	return best
+}
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskstatCounters are the cumulative counters of one /proc/diskstats line.
+// Tick counts are milliseconds; sectors are always 512 bytes.
+type DiskstatCounters struct {
+	Major, Minor int
+	Name         string
+	Reads        uint64
+	ReadSectors  uint64
+	ReadTicks    uint64
+	Writes       uint64
+	WriteSectors uint64
+	WriteTicks   uint64
+	InFlight     uint64
+	IOTicks      uint64 // time the device had I/O in flight
+}
+
+// ParseDiskstats parses /proc/diskstats content, keyed by kernel name.
+func ParseDiskstats(content string) map[string]DiskstatCounters {
+	out := map[string]DiskstatCounters{}
+	for _, line := range strings.Split(content, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 14 {
+			continue
+		}
+		n := make([]uint64, 11)
+		for i := range n {
+			n[i], _ = strconv.ParseUint(f[3+i], 10, 64)
+		}
+		c := DiskstatCounters{
+			Name:  f[2],
+			Reads: n[0], ReadSectors: n[2], ReadTicks: n[3],
+			Writes: n[4], WriteSectors: n[6], WriteTicks: n[7],
+			InFlight: n[8], IOTicks: n[9],
+		}
+		c.Major, _ = strconv.Atoi(f[0])
+		c.Minor, _ = strconv.Atoi(f[1])
+		out[c.Name] = c
+	}
+	return out
+}
+
+// BlockIO is the activity of one block device over the last interval.
+type BlockIO struct {
+	Name       string  `json:"name"`            // kernel name: sda, nvme0n1p1, dm-0, md0
+	Alias      string  `json:"alias,omitempty"` // device-mapper name, e.g. vg0-root
+	Type       string  `json:"type"`            // disk, part, dm or md
+	ReadBps    float64 `json:"read_bps"`
+	WriteBps   float64 `json:"write_bps"`
+	ReadIOPS   float64 `json:"read_iops"`
+	WriteIOPS  float64 `json:"write_iops"`
+	ReadAwait  float64 `json:"read_await_ms"`
+	WriteAwait float64 `json:"write_await_ms"`
+	Await      float64 `json:"await_ms"` // average time per request, queueing included
+	Util       float64 `json:"util"`     // percent of the interval the device was busy
+	InFlight   uint64  `json:"in_flight"`
+	ReadBytes  uint64  `json:"read_bytes"` // cumulative
+	WriteBytes uint64  `json:"write_bytes"`
+}
+
+// DiskIOSampler turns /proc/diskstats counters into rates between two
+// calls of Sample. Each consumer that needs its own interval (live view,
+// history) keeps its own sampler.
+type DiskIOSampler struct {
+	mu     sync.Mutex
+	prev   map[string]DiskstatCounters
+	prevAt time.Time
+	last   []BlockIO
+}
+
+var defaultDiskIO = &DiskIOSampler{}
+
+// GetDiskIO returns the block device rates since the previous call from
+// any caller; the first call only establishes the baseline.
+func GetDiskIO() []BlockIO { return defaultDiskIO.Sample() }
+
+// Sample returns the rates since the previous Sample. Calls less than a
+// second apart return the previous result rather than noisy rates.
+func (s *DiskIOSampler) Sample() []BlockIO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.last != nil && now.Sub(s.prevAt) < time.Second {
+		return s.last
+	}
+	data, err := os.ReadFile("/proc/diskstats")
+	if err != nil {
+		return []BlockIO{}
+	}
+	cur := ParseDiskstats(string(data))
+	dt := now.Sub(s.prevAt).Seconds()
+	out := []BlockIO{}
+	for name, c := range cur {
+		if skipBlockDevice(name) || c.Reads+c.Writes == 0 {
+			continue
+		}
+		b := BlockIO{
+			Name: name, Type: blockDeviceType(name),
+			InFlight: c.InFlight, ReadBytes: c.ReadSectors * 512, WriteBytes: c.WriteSectors * 512,
+		}
+		if b.Type == "dm" {
+			b.Alias = readSysString("/sys/block/" + name + "/dm/name")
+		}
+		if p, ok := s.prev[name]; ok && dt > 0 {
+			DiskIORates(&b, p, c, dt)
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	s.prev, s.prevAt, s.last = cur, now, out
+	return out
+}
+
+// counterDelta returns cur-prev for a /proc/diskstats counter. The tick
+// fields (and every field on 32-bit kernels) are printed as 32-bit values
+// and wrap; a counter in the upper half of that range that went backwards
+// is taken as wrapped, anything else as reset (device re-added).
+func counterDelta(prev, cur uint64) (uint64, bool) {
+	switch {
+	case cur >= prev:
+		return cur - prev, true
+	case prev <= math.MaxUint32 && prev > math.MaxUint32/2:
+		return cur + (math.MaxUint32 - prev) + 1, true
+	}
+	return 0, false
+}
+
+// DiskIORates fills the rate fields of b from two samples dt seconds
+// apart. A counter that was reset gives zeros.
+func DiskIORates(b *BlockIO, prev, cur DiskstatCounters, dt float64) {
+	var d [7]uint64
+	for i, c := range [7][2]uint64{
+		{prev.Reads, cur.Reads}, {prev.Writes, cur.Writes},
+		{prev.ReadSectors, cur.ReadSectors}, {prev.WriteSectors, cur.WriteSectors},
+		{prev.ReadTicks, cur.ReadTicks}, {prev.WriteTicks, cur.WriteTicks},
+		{prev.IOTicks, cur.IOTicks},
+	} {
+		var ok bool
+		if d[i], ok = counterDelta(c[0], c[1]); !ok {
+			return
+		}
+	}
+	reads, writes := float64(d[0]), float64(d[1])
+	rticks, wticks := float64(d[4]), float64(d[5])
+	b.ReadBps = float64(d[2]) * 512 / dt
+	b.WriteBps = float64(d[3]) * 512 / dt
+	b.ReadIOPS, b.WriteIOPS = reads/dt, writes/dt
+	if reads > 0 {
+		b.ReadAwait = rticks / reads
+	}
+	if writes > 0 {
+		b.WriteAwait = wticks / writes
+	}
+	if reads+writes > 0 {
+		b.Await = (rticks + wticks) / (reads + writes)
+	}
+	b.Util = float64(d[6]) / (dt * 1000) * 100
+	if b.Util > 100 {
+		b.Util = 100
+	}
+}
+
+// skipBlockDevice leaves out RAM-backed and virtual devices without a
+// physical disk behind them.
+func skipBlockDevice(name string) bool {
+	for _, prefix := range []string{"loop", "ram", "zram", "fd", "sr"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockDeviceType(name string) string {
+	switch {
+	case strings.HasPrefix(name, "dm-"):
+		return "dm"
+	case strings.HasPrefix(name, "md"):
+		return "md"
+	}
+	// partitions are listed in sysfs below their disk with a partition file
+	if _, err := os.Stat(filepath.Join("/sys/class/block", name, "partition")); err == nil {
+		return "part"
+	}
+	return "disk"
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDiskIORates(t *testing.T) {
+	base := DiskstatCounters{Reads: 1000, Writes: 2000, ReadSectors: 8000, WriteSectors: 16000, ReadTicks: 500, WriteTicks: 1000, IOTicks: 700}
+	tests := []struct {
+		name      string
+		prev, cur DiskstatCounters
+		want      BlockIO
+	}{
+		{
+			"steady",
+			base,
+			DiskstatCounters{Reads: 1100, Writes: 2100, ReadSectors: 8800, WriteSectors: 16800, ReadTicks: 700, WriteTicks: 1600, IOTicks: 1200},
+			BlockIO{ReadBps: 204800, WriteBps: 204800, ReadIOPS: 50, WriteIOPS: 50, ReadAwait: 2, WriteAwait: 6, Await: 4, Util: 25},
+		},
+		{
+			"32-bit tick wrap",
+			DiskstatCounters{Reads: 1000, Writes: 2000, ReadTicks: math.MaxUint32 - 99, WriteTicks: math.MaxUint32 - 199, IOTicks: math.MaxUint32 - 99},
+			DiskstatCounters{Reads: 1100, Writes: 2100, ReadTicks: 100, WriteTicks: 400, IOTicks: 400},
+			BlockIO{ReadIOPS: 50, WriteIOPS: 50, ReadAwait: 2, WriteAwait: 6, Await: 4, Util: 25},
+		},
+		{
+			"reset",
+			base,
+			DiskstatCounters{Reads: 10, Writes: 20, ReadSectors: 80, WriteSectors: 160, ReadTicks: 5, WriteTicks: 10, IOTicks: 7},
+			BlockIO{},
+		},
+		{
+			"reset of one tick counter only",
+			base,
+			DiskstatCounters{Reads: 1100, Writes: 2100, ReadSectors: 8800, WriteSectors: 16800, ReadTicks: 100, WriteTicks: 1600, IOTicks: 1200},
+			BlockIO{},
+		},
+	}
+	for _, tc := range tests {
+		var b BlockIO
+		DiskIORates(&b, tc.prev, tc.cur, 2)
+		if b != tc.want {
+			t.Errorf("%s: got %+v\nwant %+v", tc.name, b, tc.want)
+		}
+	}
+}
@@ -22,6 +22,11 @@ type Container struct {
 	MemPct  float64 `json:"mem_percent"`
 	MemUsed uint64  `json:"mem_used"`
 	MemLim  uint64  `json:"mem_limit"`
+	// cumulative since the container started
+	NetRx      uint64 `json:"net_rx"`
+	NetTx      uint64 `json:"net_tx"`
+	BlockRead  uint64 `json:"block_read"`
+	BlockWrite uint64 `json:"block_write"`
 }
 
 func GetContainers() ([]Container, error) {
@@ -49,13 +54,17 @@ func GetContainers() ([]Container, error) {
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 8*time.Second)
 	defer cancel2()
 	statsOut, err := exec.CommandContext(ctx2, "docker", "stats", "--no-stream",
-		"--format", `{{.ID}}\t{{.CPUPerc}}\t{{.MemPerc}}\t{{.MemUsage}}`).Output()
+		"--format", `{{.ID}}\t{{.CPUPerc}}\t{{.MemPerc}}\t{{.MemUsage}}\t{{.NetIO}}\t{{.BlockIO}}`).Output()
 	if err == nil {
-		type stat struct{ cpu, memPct float64; memUsed, memLim uint64 }
+		type stat struct {
+			cpu, memPct                     float64
+			memUsed, memLim                 uint64
+			netRx, netTx, blkRead, blkWrite uint64
+		}
 		statsMap := make(map[string]stat)
 		for _, line := range strings.Split(strings.TrimSpace(string(statsOut)), "\n") {
 			parts := strings.Split(line, "\t")
-			if len(parts) < 4 {
+			if len(parts) < 6 {
 				continue
 			}
 			id := parts[0]
@@ -68,7 +77,10 @@ func GetContainers() ([]Container, error) {
 				used = parseMemStr(memParts[0])
 				lim = parseMemStr(memParts[1])
 			}
-			statsMap[id] = stat{cpu, memPct, used, lim}
+			// "1.2kB / 648B" style pairs
+			rx, tx := parseIOPair(parts[4])
+			br, bw := parseIOPair(parts[5])
+			statsMap[id] = stat{cpu, memPct, used, lim, rx, tx, br, bw}
 		}
 		for i, c := range containers {
 			if s, ok := statsMap[c.ID]; ok {
@@ -76,28 +88,46 @@ func GetContainers() ([]Container, error) {
 				containers[i].MemPct = s.memPct
 				containers[i].MemUsed = s.memUsed
 				containers[i].MemLim = s.memLim
+				containers[i].NetRx = s.netRx
+				containers[i].NetTx = s.netTx
+				containers[i].BlockRead = s.blkRead
+				containers[i].BlockWrite = s.blkWrite
 			}
 		}
 	}
 	return containers, nil
 }
 
+// memSuffixes is ordered longest first so "MiB" is not mistaken for "B".
+var memSuffixes = []struct {
+	suffix string
+	mult   float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
 func parseMemStr(s string) uint64 {
 	s = strings.TrimSpace(s)
-	multipliers := map[string]uint64{
-		"B": 1, "KiB": 1024, "MiB": 1024 * 1024, "GiB": 1024 * 1024 * 1024,
-		"KB": 1000, "MB": 1000 * 1000, "GB": 1000 * 1000 * 1000,
-	}
-	for suffix, mult := range multipliers {
-		if strings.HasSuffix(s, suffix) {
-			val, _ := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
-			return uint64(val * float64(mult))
+	for _, m := range memSuffixes {
+		if strings.HasSuffix(s, m.suffix) {
+			val, _ := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, m.suffix)), 64)
+			return uint64(val * m.mult)
 		}
 	}
 	val, _ := strconv.ParseUint(s, 10, 64)
 	return val
 }
 
+func parseIOPair(s string) (uint64, uint64) {
+	parts := strings.Split(s, " / ")
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	return parseMemStr(parts[0]), parseMemStr(parts[1])
+}
+
 func ContainerAction(id, action string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
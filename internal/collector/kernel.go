@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Kernel event types.
+const (
+	KernelOOMKill    = "oom_kill"
+	KernelSegfault   = "segfault"
+	KernelIOError    = "io_error"
+	KernelFSReadOnly = "fs_readonly"
+)
+
+// KernelEvent is a kernel log message that matched one of the event types.
+type KernelEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Type      string `json:"type"`
+	PID       int32  `json:"pid,omitempty"`
+	Process   string `json:"process,omitempty"`
+	Device    string `json:"device,omitempty"`
+	Message   string `json:"message"`
+}
+
+var (
+	// Out of memory: Killed process 1234 (java) total-vm:…
+	// Memory cgroup out of memory: Killed process 1234 (java) …
+	reOOMKill = regexp.MustCompile(`(?i:out of memory): Killed process (\d+) \(([^)]*)\)`)
+	// nginx[1234]: segfault at 0 ip … error 4 in libc.so.6[…]
+	// traps: php-fpm[1234] general protection fault ip:…
+	reSegfault = regexp.MustCompile(`^(?:traps: )?(\S+)\[(\d+)\]:? (?:segfault at|general protection)`)
+	// I/O error, dev sda, sector 2048 op 0x0:(READ) …
+	// Buffer I/O error on dev sda1, logical block 0, …
+	// EXT4-fs error (device sda1): ext4_find_entry:…
+	reIOError = regexp.MustCompile(`I/O error,? (?:on )?dev(?:ice)? ([\w.:-]+)|-fs error \(device ([\w.:-]+)\)|critical medium error, dev ([\w.:-]+)`)
+	// EXT4-fs (sda1): Remounting filesystem read-only
+	// BTRFS info (device sdb1): forced readonly
+	reReadOnly = regexp.MustCompile(`\((?:device )?([\w.:-]+)\): (?:Remounting filesystem read-only|forced readonly)`)
+)
+
+// ClassifyKernelMessage reports whether msg is one of the kernel events
+// worth recording, filling in everything but the timestamp.
+func ClassifyKernelMessage(msg string) (KernelEvent, bool) {
+	e := KernelEvent{Message: msg}
+	if m := reOOMKill.FindStringSubmatch(msg); m != nil {
+		pid, _ := strconv.Atoi(m[1])
+		e.Type, e.PID, e.Process = KernelOOMKill, int32(pid), m[2]
+		return e, true
+	}
+	if m := reSegfault.FindStringSubmatch(msg); m != nil {
+		pid, _ := strconv.Atoi(m[2])
+		e.Type, e.PID, e.Process = KernelSegfault, int32(pid), m[1]
+		return e, true
+	}
+	if m := reReadOnly.FindStringSubmatch(msg); m != nil {
+		e.Type, e.Device = KernelFSReadOnly, m[1]
+		return e, true
+	}
+	if m := reIOError.FindStringSubmatch(msg); m != nil {
+		e.Type, e.Device = KernelIOError, m[1]+m[2]+m[3]
+		return e, true
+	}
+	return e, false
+}
+
+// KmsgRecord is one /dev/kmsg record: "<prio>,<seq>,<usec>,<flags>;<text>".
+type KmsgRecord struct {
+	Level   int // syslog severity, 0 = emerg … 7 = debug
+	Seq     uint64
+	Usec    uint64 // since boot
+	Message string
+}
+
+// ParseKmsg parses the first line of a /dev/kmsg record. Continuation lines
+// (" KEY=value" dictionary entries) are not records.
+func ParseKmsg(line string) (KmsgRecord, bool) {
+	header, msg, ok := strings.Cut(line, ";")
+	if !ok {
+		return KmsgRecord{}, false
+	}
+	fields := strings.Split(header, ",")
+	if len(fields) < 3 {
+		return KmsgRecord{}, false
+	}
+	prio, err1 := strconv.Atoi(fields[0])
+	seq, err2 := strconv.ParseUint(fields[1], 10, 64)
+	usec, err3 := strconv.ParseUint(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return KmsgRecord{}, false
+	}
+	return KmsgRecord{Level: prio & 7, Seq: seq, Usec: usec, Message: strings.TrimRight(msg, "\n")}, true
+}
+
+// FollowKernelLog calls fn for every kernel event logged in or after the
+// second since (unix seconds), first replaying what is still in the
+// kernel's ring buffer and then following new messages. It only returns on error. /dev/kmsg is
+// preferred; journalctl -k is the fallback where it is not readable
+// (non-root with dmesg_restrict, some containers).
+func FollowKernelLog(since int64, fn func(KernelEvent)) error {
+	f, err := os.Open("/dev/kmsg")
+	if err != nil {
+		return followJournalKernel(since, fn)
+	}
+	defer f.Close()
+
+	boot := int64(BootTime())
+	// every read returns exactly one record, which is at most 8k
+	buf := make([]byte, 16*1024)
+	for {
+		n, err := f.Read(buf)
+		if errors.Is(err, syscall.EPIPE) {
+			continue // records were overwritten before we read them
+		}
+		if err != nil {
+			return err
+		}
+		rec, ok := ParseKmsg(string(buf[:n]))
+		if !ok {
+			continue
+		}
+		ts := boot + int64(rec.Usec/1e6)
+		if ts < since {
+			continue
+		}
+		if e, ok := ClassifyKernelMessage(rec.Message); ok {
+			e.Timestamp = ts
+			fn(e)
+		}
+	}
+}
+
+func followJournalKernel(since int64, fn func(KernelEvent)) error {
+	args := []string{"-k", "-f", "-o", "json", "--no-pager"}
+	if since > 0 {
+		args = append(args, "--since", "@"+strconv.FormatInt(since, 10))
+	} else {
+		args = append(args, "-b")
+	}
+	cmd := exec.Command("journalctl", args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("no /dev/kmsg access and journalctl failed: %w", err)
+	}
+	defer cmd.Wait()
+	r := bufio.NewReaderSize(out, 64*1024)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			var entry struct {
+				Message  interface{} `json:"MESSAGE"`
+				Realtime string      `json:"__REALTIME_TIMESTAMP"`
+			}
+			if json.Unmarshal([]byte(line), &entry) == nil {
+				msg, _ := entry.Message.(string) // binary messages come as byte arrays
+				usec, _ := strconv.ParseInt(entry.Realtime, 10, 64)
+				if e, ok := ClassifyKernelMessage(msg); ok {
+					e.Timestamp = usec / 1e6
+					fn(e)
+				}
+			}
+		}
+		if err == io.EOF {
+			return fmt.Errorf("journalctl exited")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package collector
+
+import "testing"
+
+func TestClassifyKernelMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want KernelEvent
+		ok   bool
+	}{
+		{
+			"Out of memory: Killed process 1234 (java) total-vm:8123456kB, anon-rss:4012345kB, file-rss:0kB, shmem-rss:0kB, UID:1000 pgtables:9000kB oom_score_adj:0",
+			KernelEvent{Type: KernelOOMKill, PID: 1234, Process: "java"}, true,
+		},
+		{
+			"Memory cgroup out of memory: Killed process 4321 (php-fpm: pool www) total-vm:512000kB",
+			KernelEvent{Type: KernelOOMKill, PID: 4321, Process: "php-fpm: pool www"}, true,
+		},
+		{
+			"nginx[2345]: segfault at 0 ip 00007f1c2a3b4c5d sp 00007ffd1e2f3a40 error 4 in libc.so.6[7f1c2a300000+195000]",
+			KernelEvent{Type: KernelSegfault, PID: 2345, Process: "nginx"}, true,
+		},
+		{
+			"traps: php-fpm[3456] general protection fault ip:55d0c1a2b3c4 sp:7ffc9a8b7c60 error:0 in php-fpm8.2[55d0c1800000+3a0000]",
+			KernelEvent{Type: KernelSegfault, PID: 3456, Process: "php-fpm"}, true,
+		},
+		{
+			"I/O error, dev sda, sector 2048 op 0x0:(READ) flags 0x80700 phys_seg 1 prio class 2",
+			KernelEvent{Type: KernelIOError, Device: "sda"}, true,
+		},
+		{
+			"Buffer I/O error on dev sda1, logical block 0, async page read",
+			KernelEvent{Type: KernelIOError, Device: "sda1"}, true,
+		},
+		{
+			"EXT4-fs error (device nvme0n1p2): ext4_find_entry:1658: inode #2: comm ls: reading directory lblock 0",
+			KernelEvent{Type: KernelIOError, Device: "nvme0n1p2"}, true,
+		},
+		{
+			"critical medium error, dev sdb, sector 123456 op 0x0:(READ) flags 0x0 phys_seg 1 prio class 0",
+			KernelEvent{Type: KernelIOError, Device: "sdb"}, true,
+		},
+		{
+			"EXT4-fs (sda1): Remounting filesystem read-only",
+			KernelEvent{Type: KernelFSReadOnly, Device: "sda1"}, true,
+		},
+		{
+			"BTRFS info (device sdb1): forced readonly",
+			KernelEvent{Type: KernelFSReadOnly, Device: "sdb1"}, true,
+		},
+		{"EXT4-fs (sda1): mounted filesystem with ordered data mode. Quota mode: none.", KernelEvent{}, false},
+		{"oom_reaper: reaped process 1234 (java), now anon-rss:0kB", KernelEvent{}, false},
+		{"usb 1-1: new high-speed USB device number 2 using xhci_hcd", KernelEvent{}, false},
+	}
+	for _, tc := range tests {
+		got, ok := ClassifyKernelMessage(tc.msg)
+		if ok != tc.ok {
+			t.Errorf("%q: ok = %v, want %v", tc.msg, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		tc.want.Message = tc.msg
+		if got != tc.want {
+			t.Errorf("%q:\ngot  %+v\nwant %+v", tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestParseKmsg(t *testing.T) {
+	tests := []struct {
+		line string
+		want KmsgRecord
+		ok   bool
+	}{
+		{
+			"6,1234,5678901,-;EXT4-fs (sda1): mounted filesystem\n",
+			KmsgRecord{Level: 6, Seq: 1234, Usec: 5678901, Message: "EXT4-fs (sda1): mounted filesystem"}, true,
+		},
+		{
+			// facility bits above the severity are masked off
+			"11,42,100,c;Out of memory: Killed process 1 (x)",
+			KmsgRecord{Level: 3, Seq: 42, Usec: 100, Message: "Out of memory: Killed process 1 (x)"}, true,
+		},
+		{
+			// a message may itself contain ';' and ','
+			"4,7,8,-,caller=T12;a; b, c",
+			KmsgRecord{Level: 4, Seq: 7, Usec: 8, Message: "a; b, c"}, true,
+		},
+		{" SUBSYSTEM=block", KmsgRecord{}, false},
+		{" DEVICE=b8:0;x", KmsgRecord{}, false},
+		{"6,1234;short header", KmsgRecord{}, false},
+		{"x,1,2,-;bad priority", KmsgRecord{}, false},
+	}
+	for _, tc := range tests {
+		got, ok := ParseKmsg(tc.line)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("%q: got %+v, %v; want %+v, %v", tc.line, got, ok, tc.want, tc.ok)
+		}
+	}
+}
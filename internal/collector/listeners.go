@@ -0,0 +1,177 @@
+package collector
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Listener is a listening TCP socket or an unconnected UDP socket.
+type Listener struct {
+	Proto       string `json:"proto"` // tcp, tcp6, udp, udp6
+	Address     string `json:"address"`
+	Port        uint32 `json:"port"`
+	PID         int32  `json:"pid"` // 0 when the owner is not visible to us
+	Process     string `json:"process"`
+	Unit        string `json:"unit"`
+	ContainerID string `json:"container_id,omitempty"`
+	Container   string `json:"container,omitempty"` // name, from cgroup or published ports
+	Public      bool   `json:"public"`              // reachable from outside the host's private networks
+	// Ephemeral marks a UDP socket on a port from ip_local_port_range,
+	// almost always a client (DHCP, resolver, NTP) rather than a service
+	Ephemeral bool `json:"ephemeral,omitempty"`
+}
+
+// Key identifies the socket independently of the owning PID, which
+// changes on every restart of the service.
+func (l Listener) Key() string {
+	return strings.TrimSuffix(l.Proto, "6") + "/" + net.JoinHostPort(l.Address, strconv.Itoa(int(l.Port)))
+}
+
+// GetListeners lists every listening socket with its owner. Owners of
+// sockets in other users' processes are only visible when running as root.
+func GetListeners() ([]Listener, error) {
+	conns, err := psnet.Connections("inet")
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]int{} // index into out
+	lo, hi := localPortRange()
+	var out []Listener
+	for _, c := range conns {
+		proto := socketProto(c.Family, c.Type)
+		udp := strings.HasPrefix(proto, "udp")
+		if (!udp && c.Status != "LISTEN") || (udp && c.Raddr.Port != 0) {
+			continue
+		}
+		l := Listener{Proto: proto, Address: c.Laddr.IP, Port: c.Laddr.Port, PID: c.Pid, Public: isPublicAddr(c.Laddr.IP)}
+		l.Ephemeral = udp && l.Port >= lo && l.Port <= hi
+		// a socket inherited by prefork workers (nginx, php-fpm) shows up
+		// once per process; list it once, owned by the lowest visible PID,
+		// which is normally the master that opened it
+		if i, ok := byKey[l.Key()]; ok {
+			if l.PID > 0 && (out[i].PID == 0 || l.PID < out[i].PID) {
+				out[i].PID = l.PID
+			}
+			continue
+		}
+		byKey[l.Key()] = len(out)
+		out = append(out, l)
+	}
+	owners := map[int32]Listener{}
+	for i := range out {
+		l := &out[i]
+		if l.PID <= 0 {
+			continue
+		}
+		o, ok := owners[l.PID]
+		if !ok {
+			if p, err := process.NewProcess(l.PID); err == nil {
+				o.Process, _ = p.Name()
+			}
+			cg := readCgroup(l.PID)
+			o.Unit, o.ContainerID = unitFromCgroup(cg), containerFromCgroup(cg)
+			owners[l.PID] = o
+		}
+		l.Process, l.Unit, l.ContainerID = o.Process, o.Unit, o.ContainerID
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Port != out[j].Port {
+			return out[i].Port < out[j].Port
+		}
+		return out[i].Proto < out[j].Proto
+	})
+	return out, nil
+}
+
+// localPortRange reads the range the kernel picks ephemeral ports from.
+func localPortRange() (uint32, uint32) {
+	f := strings.Fields(readSysString("/proc/sys/net/ipv4/ip_local_port_range"))
+	if len(f) == 2 {
+		lo, err1 := strconv.ParseUint(f[0], 10, 16)
+		hi, err2 := strconv.ParseUint(f[1], 10, 16)
+		if err1 == nil && err2 == nil {
+			return uint32(lo), uint32(hi)
+		}
+	}
+	return 32768, 60999 // kernel default
+}
+
+// isPublicAddr reports whether a socket bound to addr can be reached from
+// outside: any wildcard bind, or a specific globally routable address.
+func isPublicAddr(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	if ip.IsUnspecified() {
+		return true
+	}
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast()
+}
+
+// MapContainers names the container behind each listener, either from the
+// owning process's cgroup or, for ports published through docker-proxy
+// or iptables, from the containers' port mappings.
+func MapContainers(listeners []Listener, containers []Container) {
+	byID := map[string]string{}
+	published := map[string]string{} // "tcp/8080" -> name
+	for _, c := range containers {
+		byID[c.ID] = c.Name
+		for _, p := range PublishedPorts(c.Ports) {
+			published[p] = c.Name
+		}
+	}
+	for i := range listeners {
+		l := &listeners[i]
+		if name, ok := byID[l.ContainerID]; ok {
+			l.Container = name
+			continue
+		}
+		if name, ok := published[strings.TrimSuffix(l.Proto, "6")+"/"+strconv.Itoa(int(l.Port))]; ok {
+			l.Container = name
+		}
+	}
+}
+
+// PublishedPorts extracts the host side of docker's port column, e.g.
+// "0.0.0.0:8080->80/tcp, :::8080->80/tcp, 0.0.0.0:9000-9001->9000-9001/udp"
+// yields tcp/8080, udp/9000 and udp/9001.
+func PublishedPorts(ports string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(ports, ",") {
+		host, target, ok := strings.Cut(strings.TrimSpace(entry), "->")
+		if !ok {
+			continue
+		}
+		proto := "tcp"
+		if _, p, found := strings.Cut(target, "/"); found {
+			proto = p
+		}
+		portRange := host[strings.LastIndex(host, ":")+1:]
+		lo, hi, isRange := strings.Cut(portRange, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(hi); err != nil {
+				continue
+			}
+		}
+		for port := a; port <= b && port-a < 1024; port++ {
+			key := proto + "/" + strconv.Itoa(port)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, key)
+			}
+		}
+	}
+	return out
+}
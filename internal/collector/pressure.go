@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PressureLine is one line of a PSI file: the share of wall time in which
+// some (or all) runnable tasks were stalled on the resource, in percent.
+type PressureLine struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"` // cumulative stall time, µs
+}
+
+// Pressure is the content of one PSI file. Full is always zero for the
+// host-wide cpu file on kernels before 5.13.
+type Pressure struct {
+	Some PressureLine `json:"some"`
+	Full PressureLine `json:"full"`
+}
+
+// PressureStats is Pressure Stall Information for cpu, memory and io, as
+// found in /proc/pressure or in a cgroup v2 directory.
+type PressureStats struct {
+	Available bool     `json:"available"` // false on kernels without PSI (or psi=0)
+	CPU       Pressure `json:"cpu"`
+	Memory    Pressure `json:"memory"`
+	IO        Pressure `json:"io"`
+}
+
+// GetPressure reads the host-wide PSI from /proc/pressure.
+func GetPressure() PressureStats {
+	return readPressureDir("/proc/pressure", "")
+}
+
+// readPressureDir reads <dir>/{cpu,memory,io}<suffix>: /proc/pressure has
+// no suffix while cgroup directories name the files cpu.pressure etc.
+func readPressureDir(dir string, suffix string) PressureStats {
+	var ps PressureStats
+	for _, r := range []struct {
+		name string
+		dst  *Pressure
+	}{{"cpu", &ps.CPU}, {"memory", &ps.Memory}, {"io", &ps.IO}} {
+		data, err := os.ReadFile(filepath.Join(dir, r.name+suffix))
+		if err != nil {
+			continue
+		}
+		if p, ok := ParsePressure(string(data)); ok {
+			*r.dst = p
+			ps.Available = true
+		}
+	}
+	return ps
+}
+
+// ParsePressure parses the "some avg10=… avg60=… avg300=… total=…" and
+// "full …" lines of a PSI file.
+func ParsePressure(content string) (Pressure, bool) {
+	var p Pressure
+	ok := false
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var dst *PressureLine
+		switch fields[0] {
+		case "some":
+			dst = &p.Some
+		case "full":
+			dst = &p.Full
+		default:
+			continue
+		}
+		for _, f := range fields[1:] {
+			k, v, found := strings.Cut(f, "=")
+			if !found {
+				continue
+			}
+			switch k {
+			case "avg10":
+				dst.Avg10, _ = strconv.ParseFloat(v, 64)
+			case "avg60":
+				dst.Avg60, _ = strconv.ParseFloat(v, 64)
+			case "avg300":
+				dst.Avg300, _ = strconv.ParseFloat(v, 64)
+			case "total":
+				dst.Total, _ = strconv.ParseUint(v, 10, 64)
+			}
+		}
+		ok = true
+	}
+	return p, ok
+}
+
+// cgroup2Root is where the unified hierarchy is mounted: /sys/fs/cgroup on
+// pure cgroup v2 hosts, /sys/fs/cgroup/unified on hybrid ones.
+func cgroup2Root() string {
+	for _, dir := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// GetCgroupPressure reads the PSI files of a cgroup v2 group such as
+// "/system.slice/nginx.service". Available is false without cgroup v2.
+func GetCgroupPressure(group string) PressureStats {
+	root := cgroup2Root()
+	if root == "" || group == "" || strings.Contains(group, "..") {
+		return PressureStats{}
+	}
+	return readPressureDir(filepath.Join(root, group), ".pressure")
+}
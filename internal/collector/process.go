@@ -1,7 +1,14 @@
 package collector
 
 import (
+	"os"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/shirou/gopsutil/v3/process"
 )
@@ -15,69 +22,271 @@ type ProcessInfo struct {
 	MemRSS     uint64  `json:"mem_rss"`
 	Status     string  `json:"status"`
 	Cmdline    string  `json:"cmdline"`
+	PPID       int32   `json:"ppid"`
+	Threads    int32   `json:"threads"`
+	StartTime  int64   `json:"start_time"` // unix seconds
+	Nice       int32   `json:"nice"`
+	Cgroup     string  `json:"cgroup"`
+	Container  string  `json:"container_id,omitempty"` // short docker/podman/containerd ID
+}
+
+// ProcessNode is a process with its children. The Total* fields cover the
+// whole subtree, so a master with 40 workers shows their combined load.
+type ProcessNode struct {
+	ProcessInfo
+	TotalCPU    float64        `json:"total_cpu_percent"`
+	TotalMemPct float64        `json:"total_mem_percent"`
+	TotalRSS    uint64         `json:"total_mem_rss"`
+	Count       int            `json:"count"` // processes in the subtree, itself included
+	Children    []*ProcessNode `json:"children"`
+}
+
+// cpuSampler turns cumulative per-process CPU times into current
+// utilisation by diffing against the previous call. gopsutil's
+// CPUPercent is the average since process start, which hides a process
+// that only just started spinning.
+type cpuSampler struct {
+	mu   sync.Mutex
+	at   time.Time
+	prev map[int32]procTimes
+	last map[int32]float64
+}
+
+type procTimes struct {
+	cpu     float64 // user+system seconds
+	created int64   // ms, tells a reused PID apart
+}
+
+const (
+	// without a baseline this recent, one is taken over sampleWindow
+	cpuBaselineStale = 5 * time.Minute
+	cpuSampleWindow  = 500 * time.Millisecond
+	// callers closer together than this share the previous result
+	// instead of diffing over a uselessly short interval
+	cpuMinInterval = time.Second
+)
+
+var procCPU = &cpuSampler{}
+
+func readProcTimes(procs []*process.Process) map[int32]procTimes {
+	out := make(map[int32]procTimes, len(procs))
+	for _, p := range procs {
+		t, err := p.Times()
+		if err != nil {
+			continue
+		}
+		created, _ := p.CreateTime()
+		out[p.Pid] = procTimes{cpu: t.User + t.System, created: created}
+	}
+	return out
+}
+
+// percentages returns the CPU usage of each process since the previous
+// call (100 = one core).
+func (s *cpuSampler) percentages(procs []*process.Process) map[int32]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.at) > cpuBaselineStale {
+		s.prev = readProcTimes(procs)
+		time.Sleep(cpuSampleWindow)
+		s.at, now = now, time.Now()
+	} else if now.Sub(s.at) < cpuMinInterval && s.last != nil {
+		return s.last
+	}
+
+	cur := readProcTimes(procs)
+	elapsed := now.Sub(s.at).Seconds()
+	result := make(map[int32]float64, len(cur))
+	for pid, c := range cur {
+		p, ok := s.prev[pid]
+		switch {
+		case ok && p.created == c.created && c.cpu >= p.cpu:
+			result[pid] = (c.cpu - p.cpu) / elapsed * 100
+		case c.created > 0 && c.created >= s.at.UnixMilli():
+			// started since the last sample: its lifetime average covers
+			// exactly the part of the interval it existed for. Start times
+			// are only tick-accurate, so very young processes are floored
+			// at a second rather than reporting absurd percentages.
+			life := float64(now.UnixMilli()-c.created) / 1000
+			if life < 1 {
+				life = 1
+			}
+			result[pid] = c.cpu / life * 100
+		}
+	}
+	s.prev, s.last, s.at = cur, result, now
+	return result
+}
+
+// lastPercent returns pid's utilisation from the most recent sample, if
+// that sample is still fresh.
+func (s *cpuSampler) lastPercent(pid int32) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.at) > cpuBaselineStale {
+		return 0, false
+	}
+	v, ok := s.last[pid]
+	return v, ok
+}
+
+// processInfo reads the list row of p; cpu comes from the sampler.
+func processInfo(p *process.Process, cpu float64) ProcessInfo {
+	name, _ := p.Name()
+	username, _ := p.Username()
+	memPct, _ := p.MemoryPercent()
+	memInfo, _ := p.MemoryInfo()
+	statuses, _ := p.Status()
+	cmdline, _ := p.Cmdline()
+	ppid, _ := p.Ppid()
+	threads, _ := p.NumThreads()
+	created, _ := p.CreateTime()
+	nice := readNice(p.Pid)
+	cg := readCgroup(p.Pid)
+
+	var rss uint64
+	if memInfo != nil {
+		rss = memInfo.RSS
+	}
+	status := ""
+	if len(statuses) > 0 {
+		status = statuses[0]
+	}
+
+	return ProcessInfo{
+		PID:        p.Pid,
+		Name:       name,
+		Username:   username,
+		CPUPercent: cpu,
+		MemPercent: memPct,
+		MemRSS:     rss,
+		Status:     status,
+		Cmdline:    cmdline,
+		PPID:       ppid,
+		Threads:    threads,
+		StartTime:  created / 1000,
+		Nice:       nice,
+		Cgroup:     cg,
+		Container:  containerFromCgroup(cg),
+	}
 }
 
 func GetProcesses(sortBy string, sortDir string, limit int) ([]ProcessInfo, error) {
+	infos, _, err := QueryProcesses(ProcessQuery{Sort: sortBy, Dir: sortDir, Limit: limit})
+	return infos, err
+}
+
+func listProcesses() ([]ProcessInfo, error) {
 	procs, err := process.Processes()
 	if err != nil {
 		return nil, err
 	}
+	cpus := procCPU.percentages(procs)
 
-	var infos []ProcessInfo
+	infos := make([]ProcessInfo, 0, len(procs))
 	for _, p := range procs {
-		name, _ := p.Name()
-		username, _ := p.Username()
-		cpu, _ := p.CPUPercent()
-		memPct, _ := p.MemoryPercent()
-		memInfo, _ := p.MemoryInfo()
-		statuses, _ := p.Status()
-		cmdline, _ := p.Cmdline()
-
-		var rss uint64
-		if memInfo != nil {
-			rss = memInfo.RSS
-		}
-		status := ""
-		if len(statuses) > 0 {
-			status = statuses[0]
+		infos = append(infos, processInfo(p, cpus[p.Pid]))
+	}
+	return infos, nil
+}
+
+// GetProcessTree arranges every process under its parent. Processes whose
+// parent is not visible (PID 1, kthreadd, or anything reparented outside
+// our PID namespace) are roots. Siblings are ordered by subtree CPU.
+func GetProcessTree() ([]*ProcessNode, error) {
+	infos, err := GetProcesses("cpu", "desc", 0)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[int32]*ProcessNode, len(infos))
+	for _, info := range infos {
+		nodes[info.PID] = &ProcessNode{ProcessInfo: info, Children: []*ProcessNode{}}
+	}
+	var roots []*ProcessNode
+	for _, info := range infos {
+		n := nodes[info.PID]
+		if parent, ok := nodes[info.PPID]; ok && info.PPID != info.PID {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
 		}
+	}
+	for _, r := range roots {
+		sumSubtree(r)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].TotalCPU > roots[j].TotalCPU })
+	return roots, nil
+}
 
-		infos = append(infos, ProcessInfo{
-			PID:        p.Pid,
-			Name:       name,
-			Username:   username,
-			CPUPercent: cpu,
-			MemPercent: memPct,
-			MemRSS:     rss,
-			Status:     status,
-			Cmdline:    cmdline,
-		})
-	}
-
-	asc := sortDir == "asc"
-	switch sortBy {
-	case "mem":
-		sort.Slice(infos, func(i, j int) bool {
-			if asc { return infos[i].MemPercent < infos[j].MemPercent }
-			return infos[i].MemPercent > infos[j].MemPercent
-		})
-	default:
-		sort.Slice(infos, func(i, j int) bool {
-			if asc { return infos[i].CPUPercent < infos[j].CPUPercent }
-			return infos[i].CPUPercent > infos[j].CPUPercent
-		})
-	}
-
-	if limit > 0 && len(infos) > limit {
-		infos = infos[:limit]
+func sumSubtree(n *ProcessNode) {
+	n.TotalCPU, n.TotalMemPct, n.TotalRSS, n.Count = n.CPUPercent, float64(n.MemPercent), n.MemRSS, 1
+	for _, c := range n.Children {
+		sumSubtree(c)
+		n.TotalCPU += c.TotalCPU
+		n.TotalMemPct += c.TotalMemPct
+		n.TotalRSS += c.TotalRSS
+		n.Count += c.Count
 	}
-	return infos, nil
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].TotalCPU > n.Children[j].TotalCPU })
 }
 
-func KillProcess(pid int32) error {
-	p, err := process.NewProcess(pid)
+// readNice reads the nice value from /proc/<pid>/stat. gopsutil's Nice
+// returns the kernel priority field (20 for nice 0) instead.
+func readNice(pid int32) int32 {
+	data, err := os.ReadFile(procPath(pid, "stat"))
+	if err != nil {
+		return 0
+	}
+	// the command name may contain spaces and parentheses; fields are
+	// counted from the last ')', where field 3 (state) is index 0
+	s := string(data)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 17 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fields[16])
+	return int32(n)
+}
+
+func readCgroup(pid int32) string {
+	data, err := os.ReadFile(procPath(pid, "cgroup"))
 	if err != nil {
-		return err
+		return ""
+	}
+	return parseCgroup(string(data))
+}
+
+// parseCgroup picks the most useful path from /proc/<pid>/cgroup: the
+// unified (v2) hierarchy, else the systemd or memory v1 controller.
+func parseCgroup(content string) string {
+	paths := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) == 3 {
+			paths[parts[1]] = parts[2]
+		}
+	}
+	for _, ctrl := range []string{"", "name=systemd", "memory"} {
+		if p := paths[ctrl]; p != "" && p != "/" {
+			return p
+		}
+	}
+	return paths[""]
+}
+
+// containerIDPattern matches the 64-hex container ID that docker, podman
+// and containerd put in cgroup paths, e.g. /docker/<id>,
+// /system.slice/docker-<id>.scope or cri-containerd-<id>.scope.
+var containerIDPattern = regexp.MustCompile(`(?:docker|libpod|containerd|crio)[-/:]([0-9a-f]{64})`)
+
+func containerFromCgroup(path string) string {
+	if m := containerIDPattern.FindStringSubmatch(path); m != nil {
+		return m[1][:12]
 	}
-	return p.Kill()
+	return ""
+}
+
+func KillProcess(pid int32) error {
+	return SignalProcess(pid, syscall.SIGKILL)
 }
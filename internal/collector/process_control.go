@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+var (
+	// ErrProtected is returned for processes the panel refuses to touch.
+	ErrProtected = errors.New("process is protected")
+	// ErrInvalidArgument wraps rejected signal, priority and CPU values.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+var signalNames = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL, "USR1": syscall.SIGUSR1, "USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM, "STOP": syscall.SIGSTOP, "CONT": syscall.SIGCONT,
+}
+
+// ParseSignal accepts "TERM", "SIGTERM" or "15".
+func ParseSignal(s string) (syscall.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, sig := range signalNames {
+			if int(sig) == n {
+				return sig, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: unsupported signal %q", ErrInvalidArgument, s)
+}
+
+// checkProtected refuses PID 1, the kernel thread parent, GoPanel itself
+// and its parent (usually systemd or the shell that started it), and any
+// PID that would address a process group.
+func checkProtected(pid int32) error {
+	if pid <= 2 || int(pid) == os.Getpid() || int(pid) == os.Getppid() {
+		return ErrProtected
+	}
+	return nil
+}
+
+func SignalProcess(pid int32, sig syscall.Signal) error {
+	if err := checkProtected(pid); err != nil {
+		return err
+	}
+	return syscall.Kill(int(pid), sig)
+}
+
+// Renice sets the scheduling priority, -20 (highest) to 19.
+func Renice(pid int32, nice int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("%w: nice must be between -20 and 19", ErrInvalidArgument)
+	}
+	if err := checkProtected(pid); err != nil {
+		return err
+	}
+	return eachThread(pid, func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice)
+	})
+}
+
+var ioClasses = map[string]int{"none": 0, "realtime": 1, "best-effort": 2, "idle": 3}
+
+// Ionice sets the I/O scheduling class (none, realtime, best-effort, idle)
+// and, for realtime and best-effort, the level 0 (highest) to 7.
+func Ionice(pid int32, class string, level int) error {
+	c, ok := ioClasses[class]
+	if !ok {
+		return fmt.Errorf("%w: unknown io class %q", ErrInvalidArgument, class)
+	}
+	if level < 0 || level > 7 {
+		return fmt.Errorf("%w: io level must be between 0 and 7", ErrInvalidArgument)
+	}
+	if c == 0 || c == 3 {
+		level = 0
+	}
+	if err := checkProtected(pid); err != nil {
+		return err
+	}
+	const ioprioWhoProcess, ioprioClassShift = 1, 13
+	return eachThread(pid, func(tid int) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(c<<ioprioClassShift|level))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// cpuMask is a sched_setaffinity bitmask for up to maxCPUs CPUs.
+type cpuMask [16]uint64
+
+const maxCPUs = len(cpuMask{}) * 64
+
+// SetAffinity pins pid to the CPUs in list, e.g. "0-3,6". The kernel
+// rejects a mask without any online CPU with EINVAL.
+func SetAffinity(pid int32, list string) error {
+	cpus, err := ParseCPUList(list)
+	if err != nil {
+		return err
+	}
+	var mask cpuMask
+	for _, c := range cpus {
+		mask[c/64] |= 1 << (c % 64)
+	}
+	if err := checkProtected(pid); err != nil {
+		return err
+	}
+	return eachThread(pid, func(tid int) error {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// eachThread applies fn to every thread of pid: nice, I/O priority and
+// affinity are per thread on Linux, so changing only the PID would leave
+// the worker threads of a multi-threaded process untouched. Threads
+// created afterwards inherit the setting from the thread that creates
+// them; one that exits in the meantime is skipped.
+func eachThread(pid int32, fn func(tid int) error) error {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return fn(int(pid))
+	}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if err := fn(tid); err != nil && (tid == int(pid) || !errors.Is(err, syscall.ESRCH)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAffinity returns the CPUs pid may run on as a list like "0-3,6".
+func GetAffinity(pid int32) (string, error) {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, uintptr(pid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return "", errno
+	}
+	var cpus []int
+	for i := 0; i < len(mask)*64; i++ {
+		if mask[i/64]&(1<<(i%64)) != 0 {
+			cpus = append(cpus, i)
+		}
+	}
+	return FormatCPUList(cpus), nil
+}
+
+// ParseCPUList parses the kernel's CPU list format ("0-3,6"). CPUs past
+// the affinity mask are rejected before any range is expanded.
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("%w: cpu list %q", ErrInvalidArgument, list)
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || b < a {
+				return nil, fmt.Errorf("%w: cpu list %q", ErrInvalidArgument, list)
+			}
+		}
+		if a < 0 || b >= maxCPUs {
+			return nil, fmt.Errorf("%w: cpu %d out of range", ErrInvalidArgument, b)
+		}
+		for c := a; c <= b; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("%w: empty cpu list", ErrInvalidArgument)
+	}
+	return cpus, nil
+}
+
+// FormatCPUList is the inverse of ParseCPUList for a sorted list.
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// SignalResult is the outcome of a bulk action for one process.
+type SignalResult struct {
+	PID   int32  `json:"pid"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// SignalByName sends sig to every process whose name is exactly name.
+// Protected processes are reported as failures, not skipped silently.
+func SignalByName(name string, sig syscall.Signal) ([]SignalResult, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name required", ErrInvalidArgument)
+	}
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	results := []SignalResult{}
+	for _, p := range procs {
+		if n, _ := p.Name(); n != name {
+			continue
+		}
+		r := SignalResult{PID: p.Pid, OK: true}
+		if err := SignalProcess(p.Pid, sig); err != nil {
+			r.OK, r.Error = false, err.Error()
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
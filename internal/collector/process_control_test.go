@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list string
+		want []int
+	}{
+		{"0", []int{0}},
+		{"0-3,6", []int{0, 1, 2, 3, 6}},
+		{" 1 , 4-5 ", []int{1, 4, 5}},
+		{"1020-1023", []int{1020, 1021, 1022, 1023}},
+	}
+	for _, tc := range tests {
+		got, err := ParseCPUList(tc.list)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v; want %v", tc.list, got, err, tc.want)
+		}
+	}
+	for _, list := range []string{"", "a", "3-1", "-1", "1024", "0-1024", "0-2000000000"} {
+		if _, err := ParseCPUList(list); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseCPUList(%q) error = %v, want ErrInvalidArgument", list, err)
+		}
+	}
+}
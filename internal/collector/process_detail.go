@@ -0,0 +1,330 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessDetail is everything the panel shows for a single process. Fields
+// that need more privileges than we have are left empty.
+type ProcessDetail struct {
+	ProcessInfo
+	Exe           string          `json:"exe"`
+	Cwd           string          `json:"cwd"`
+	Args          []string        `json:"args"`
+	Environ       []string        `json:"environ"` // KEY=VALUE, secrets masked
+	FDCount       int             `json:"fd_count"`
+	FDs           []ProcessFD     `json:"fds"` // first maxDetailFDs
+	Sockets       []ProcessSocket `json:"sockets"`
+	Memory        MemoryMaps      `json:"memory_maps"`
+	IO            *ProcessIO      `json:"io"`
+	Limits        []ProcessLimit  `json:"limits"`
+	Affinity      string          `json:"affinity"`                 // CPU list, e.g. "0-3"
+	Unit          string          `json:"unit"`                     // owning systemd unit, from the cgroup
+	ContainerName string          `json:"container_name,omitempty"` // of the embedded Container ID
+}
+
+type ProcessFD struct {
+	FD     int    `json:"fd"`
+	Type   string `json:"type"` // file, socket, pipe, anon, device
+	Target string `json:"target"`
+}
+
+type ProcessSocket struct {
+	Proto  string `json:"proto"` // tcp, tcp6, udp, udp6
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	State  string `json:"state"`
+}
+
+type ProcessIO struct {
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	ReadCount  uint64 `json:"read_count"`
+	WriteCount uint64 `json:"write_count"`
+}
+
+// ProcessLimit is one row of /proc/<pid>/limits. Soft and Hard are kept as
+// text because "unlimited" is common.
+type ProcessLimit struct {
+	Name  string `json:"name"`
+	Soft  string `json:"soft"`
+	Hard  string `json:"hard"`
+	Units string `json:"units"`
+}
+
+// MemoryMaps summarises /proc/<pid>/smaps: totals plus the mappings that
+// hold the most resident memory, grouped by backing file. Sizes in bytes.
+type MemoryMaps struct {
+	Count   int             `json:"count"`
+	Size    uint64          `json:"size"`
+	RSS     uint64          `json:"rss"`
+	PSS     uint64          `json:"pss"`
+	Private uint64          `json:"private"`
+	Shared  uint64          `json:"shared"`
+	Swap    uint64          `json:"swap"`
+	Top     []MemoryMapPath `json:"top"`
+}
+
+type MemoryMapPath struct {
+	Path  string `json:"path"` // "[anon]" for anonymous memory
+	Count int    `json:"count"`
+	Size  uint64 `json:"size"`
+	RSS   uint64 `json:"rss"`
+	Swap  uint64 `json:"swap"`
+}
+
+const (
+	maxDetailFDs  = 500
+	maxMemoryMaps = 20
+)
+
+// GetProcessDetail collects the detail view of pid. The container name is
+// not resolved here; callers that have the docker list fill it in.
+func GetProcessDetail(pid int32) (*ProcessDetail, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	cpu, ok := procCPU.lastPercent(pid)
+	if !ok {
+		cpu, _ = p.CPUPercent()
+	}
+	d := &ProcessDetail{ProcessInfo: processInfo(p, cpu)}
+	d.Exe, _ = p.Exe()
+	d.Cwd, _ = p.Cwd()
+	d.Args, _ = p.CmdlineSlice()
+	if env, err := p.Environ(); err == nil {
+		d.Environ = MaskEnviron(env)
+	}
+	d.FDCount, d.FDs = readFDs(pid)
+	if conns, err := psnet.ConnectionsPid("inet", pid); err == nil {
+		for _, c := range conns {
+			d.Sockets = append(d.Sockets, ProcessSocket{
+				Proto:  socketProto(c.Family, c.Type),
+				Local:  fmt.Sprintf("%s:%d", c.Laddr.IP, c.Laddr.Port),
+				Remote: fmt.Sprintf("%s:%d", c.Raddr.IP, c.Raddr.Port),
+				State:  c.Status,
+			})
+		}
+	}
+	if data, err := os.ReadFile(procPath(pid, "smaps")); err == nil {
+		d.Memory = parseSmaps(string(data))
+	}
+	if io, err := p.IOCounters(); err == nil {
+		d.IO = &ProcessIO{ReadBytes: io.ReadBytes, WriteBytes: io.WriteBytes, ReadCount: io.ReadCount, WriteCount: io.WriteCount}
+	}
+	if data, err := os.ReadFile(procPath(pid, "limits")); err == nil {
+		d.Limits = parseLimits(string(data))
+	}
+	d.Affinity, _ = GetAffinity(pid)
+	d.Unit = unitFromCgroup(d.Cgroup)
+	return d, nil
+}
+
+func procPath(pid int32, name string) string {
+	return "/proc/" + strconv.Itoa(int(pid)) + "/" + name
+}
+
+// secretEnvKey matches variable names whose values should never leave the
+// host. URLs with embedded credentials are masked separately.
+var secretEnvKey = regexp.MustCompile(`(?i)pass|secret|token|key|auth|credential|private|cookie|session|salt|dsn`)
+
+var urlUserinfo = regexp.MustCompile(`(://[^/:@\s]*:)[^@\s]+@`)
+
+// MaskEnviron masks the values of secret-looking variables and passwords
+// embedded in URLs such as DATABASE_URL=postgres://app:pw@db/app.
+func MaskEnviron(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		k, v, found := strings.Cut(kv, "=")
+		switch {
+		case !found:
+		case secretEnvKey.MatchString(k) && v != "":
+			v = "******"
+		default:
+			v = urlUserinfo.ReplaceAllString(v, "${1}******@")
+		}
+		if found {
+			kv = k + "=" + v
+		}
+		out = append(out, kv)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func readFDs(pid int32) (int, []ProcessFD) {
+	entries, err := os.ReadDir(procPath(pid, "fd"))
+	if err != nil {
+		return 0, nil
+	}
+	var fds []ProcessFD
+	for _, e := range entries {
+		if len(fds) >= maxDetailFDs {
+			break
+		}
+		n, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(procPath(pid, "fd/"+e.Name()))
+		if err != nil {
+			continue
+		}
+		fds = append(fds, ProcessFD{FD: n, Type: fdType(target), Target: target})
+	}
+	sort.Slice(fds, func(i, j int) bool { return fds[i].FD < fds[j].FD })
+	return len(entries), fds
+}
+
+func fdType(target string) string {
+	switch {
+	case strings.HasPrefix(target, "socket:"):
+		return "socket"
+	case strings.HasPrefix(target, "pipe:"):
+		return "pipe"
+	case strings.HasPrefix(target, "anon_inode:"):
+		return "anon"
+	case strings.HasPrefix(target, "/dev/"):
+		return "device"
+	}
+	return "file"
+}
+
+func socketProto(family, typ uint32) string {
+	proto := "tcp"
+	if typ == 2 { // SOCK_DGRAM
+		proto = "udp"
+	}
+	if family == 10 { // AF_INET6
+		proto += "6"
+	}
+	return proto
+}
+
+// parseSmaps sums /proc/<pid>/smaps per backing path. Each mapping starts
+// with a header line ("addr-addr perms offset dev inode [path]") followed
+// by "Key:   N kB" lines.
+func parseSmaps(content string) MemoryMaps {
+	var mm MemoryMaps
+	byPath := map[string]*MemoryMapPath{}
+	var cur *MemoryMapPath
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if !strings.HasSuffix(fields[0], ":") {
+			if len(fields) < 5 || !strings.Contains(fields[0], "-") {
+				continue
+			}
+			path := "[anon]"
+			if len(fields) > 5 {
+				path = strings.Join(fields[5:], " ")
+			}
+			cur = byPath[path]
+			if cur == nil {
+				cur = &MemoryMapPath{Path: path}
+				byPath[path] = cur
+			}
+			cur.Count++
+			mm.Count++
+			continue
+		}
+		if cur == nil || len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		b := kb * 1024
+		switch fields[0] {
+		case "Size:":
+			cur.Size += b
+			mm.Size += b
+		case "Rss:":
+			cur.RSS += b
+			mm.RSS += b
+		case "Pss:":
+			mm.PSS += b
+		case "Private_Clean:", "Private_Dirty:":
+			mm.Private += b
+		case "Shared_Clean:", "Shared_Dirty:":
+			mm.Shared += b
+		case "Swap:":
+			cur.Swap += b
+			mm.Swap += b
+		}
+	}
+	for _, p := range byPath {
+		mm.Top = append(mm.Top, *p)
+	}
+	sort.Slice(mm.Top, func(i, j int) bool { return mm.Top[i].RSS > mm.Top[j].RSS })
+	if len(mm.Top) > maxMemoryMaps {
+		mm.Top = mm.Top[:maxMemoryMaps]
+	}
+	return mm
+}
+
+// parseLimits parses /proc/<pid>/limits, whose columns are aligned with
+// spaces and whose limit names contain spaces too:
+//
+//	Limit                     Soft Limit           Hard Limit           Units
+//	Max open files            1024                 1048576              files
+func parseLimits(content string) []ProcessLimit {
+	lines := strings.Split(content, "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	header := lines[0]
+	softAt := strings.Index(header, "Soft Limit")
+	hardAt := strings.Index(header, "Hard Limit")
+	unitsAt := strings.Index(header, "Units")
+	if softAt < 0 || hardAt < softAt || unitsAt < hardAt {
+		return nil
+	}
+	col := func(line string, from, to int) string {
+		if from >= len(line) {
+			return ""
+		}
+		if to > len(line) || to < 0 {
+			to = len(line)
+		}
+		return strings.TrimSpace(line[from:to])
+	}
+	var out []ProcessLimit
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		out = append(out, ProcessLimit{
+			Name:  col(line, 0, softAt),
+			Soft:  col(line, softAt, hardAt),
+			Hard:  col(line, hardAt, unitsAt),
+			Units: col(line, unitsAt, -1),
+		})
+	}
+	return out
+}
+
+// unitFromCgroup returns the innermost systemd unit in a cgroup path, e.g.
+// nginx.service for /system.slice/nginx.service.
+func unitFromCgroup(path string) string {
+	parts := strings.Split(path, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		p := parts[i]
+		if strings.HasSuffix(p, ".service") || strings.HasSuffix(p, ".scope") {
+			return p
+		}
+	}
+	return ""
+}
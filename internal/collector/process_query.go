@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProcessQuery filters, sorts and pages the process list. Zero values
+// mean "no filter".
+type ProcessQuery struct {
+	User   string
+	Name   string   // regular expression, matched against name and cmdline
+	States []string // gopsutil status names: running, sleep, zombie, stop, idle...
+	MinCPU float64
+	MinMem float64
+	Sort   string // cpu (default), mem, rss, pid, name, start, threads
+	Dir    string // desc (default) or asc
+	Offset int
+	Limit  int
+}
+
+var processSorts = map[string]func(a, b *ProcessInfo) bool{
+	"cpu":     func(a, b *ProcessInfo) bool { return a.CPUPercent < b.CPUPercent },
+	"mem":     func(a, b *ProcessInfo) bool { return a.MemPercent < b.MemPercent },
+	"rss":     func(a, b *ProcessInfo) bool { return a.MemRSS < b.MemRSS },
+	"pid":     func(a, b *ProcessInfo) bool { return a.PID < b.PID },
+	"name":    func(a, b *ProcessInfo) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"start":   func(a, b *ProcessInfo) bool { return a.StartTime < b.StartTime },
+	"threads": func(a, b *ProcessInfo) bool { return a.Threads < b.Threads },
+}
+
+// QueryProcesses returns one page of matching processes and the number of
+// matches before paging.
+func QueryProcesses(q ProcessQuery) ([]ProcessInfo, int, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, 0, err
+	}
+	all, err := listProcesses()
+	if err != nil {
+		return nil, 0, err
+	}
+	var infos []ProcessInfo
+	for _, p := range all {
+		if match(&p) {
+			infos = append(infos, p)
+		}
+	}
+	SortProcesses(infos, q.Sort, q.Dir)
+
+	total := len(infos)
+	if q.Offset > 0 {
+		if q.Offset >= len(infos) {
+			return []ProcessInfo{}, total, nil
+		}
+		infos = infos[q.Offset:]
+	}
+	if q.Limit > 0 && len(infos) > q.Limit {
+		infos = infos[:q.Limit]
+	}
+	return infos, total, nil
+}
+
+func (q ProcessQuery) matcher() (func(*ProcessInfo) bool, error) {
+	var re *regexp.Regexp
+	if q.Name != "" {
+		var err error
+		if re, err = regexp.Compile("(?i)" + q.Name); err != nil {
+			return nil, fmt.Errorf("%w: name pattern: %v", ErrInvalidArgument, err)
+		}
+	}
+	states := map[string]bool{}
+	for _, s := range q.States {
+		states[strings.ToLower(s)] = true
+	}
+	return func(p *ProcessInfo) bool {
+		switch {
+		case q.User != "" && p.Username != q.User:
+			return false
+		case re != nil && !re.MatchString(p.Name) && !re.MatchString(p.Cmdline):
+			return false
+		case len(states) > 0 && !states[p.Status]:
+			return false
+		case p.CPUPercent < q.MinCPU || float64(p.MemPercent) < q.MinMem:
+			return false
+		}
+		return true
+	}, nil
+}
+
+// SortProcesses sorts by one of the processSorts keys (cpu when unknown),
+// descending unless dir is "asc". Ties are broken by PID so pages are
+// stable between requests.
+func SortProcesses(infos []ProcessInfo, by, dir string) {
+	less, ok := processSorts[by]
+	if !ok {
+		less = processSorts["cpu"]
+	}
+	asc := dir == "asc"
+	sort.SliceStable(infos, func(i, j int) bool {
+		a, b := &infos[i], &infos[j]
+		if less(a, b) {
+			return asc
+		}
+		if less(b, a) {
+			return !asc
+		}
+		return a.PID < b.PID
+	})
+}
+
+// ProcessGroup sums the processes sharing a name or a user.
+type ProcessGroup struct {
+	Key        string  `json:"key"`
+	Count      int     `json:"count"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemPercent float64 `json:"mem_percent"`
+	MemRSS     uint64  `json:"mem_rss"`
+	Threads    int32   `json:"threads"`
+	PIDs       []int32 `json:"pids"`
+}
+
+// GroupProcesses aggregates infos by "name" or "user", largest CPU first
+// (or by count, mem, rss when sortBy says so).
+func GroupProcesses(infos []ProcessInfo, by, sortBy string) ([]ProcessGroup, error) {
+	var key func(*ProcessInfo) string
+	switch by {
+	case "name":
+		key = func(p *ProcessInfo) string { return p.Name }
+	case "user":
+		key = func(p *ProcessInfo) string { return p.Username }
+	default:
+		return nil, fmt.Errorf("%w: group must be name or user", ErrInvalidArgument)
+	}
+	groups := map[string]*ProcessGroup{}
+	for i := range infos {
+		p := &infos[i]
+		k := key(p)
+		g, ok := groups[k]
+		if !ok {
+			g = &ProcessGroup{Key: k}
+			groups[k] = g
+		}
+		g.Count++
+		g.CPUPercent += p.CPUPercent
+		g.MemPercent += float64(p.MemPercent)
+		g.MemRSS += p.MemRSS
+		g.Threads += p.Threads
+		g.PIDs = append(g.PIDs, p.PID)
+	}
+	out := make([]ProcessGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.PIDs, func(i, j int) bool { return g.PIDs[i] < g.PIDs[j] })
+		out = append(out, *g)
+	}
+	less := map[string]func(a, b *ProcessGroup) bool{
+		"count": func(a, b *ProcessGroup) bool { return a.Count > b.Count },
+		"mem":   func(a, b *ProcessGroup) bool { return a.MemPercent > b.MemPercent },
+		"rss":   func(a, b *ProcessGroup) bool { return a.MemRSS > b.MemRSS },
+	}[sortBy]
+	if less == nil {
+		less = func(a, b *ProcessGroup) bool { return a.CPUPercent > b.CPUPercent }
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if less(&out[i], &out[j]) || less(&out[j], &out[i]) {
+			return less(&out[i], &out[j])
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
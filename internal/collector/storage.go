@@ -0,0 +1,361 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StorageStatus covers the storage layers above the raw disks: md software
+// RAID, LVM and ZFS. Sections whose tools are not installed stay empty.
+type StorageStatus struct {
+	MD       []MDArray    `json:"md"`
+	VGs      []LVMGroup   `json:"lvm_vgs"`
+	LVs      []LVMVolume  `json:"lvm_lvs"`
+	Pools    []ZFSPool    `json:"zfs_pools"`
+	Datasets []ZFSDataset `json:"zfs_datasets"`
+}
+
+type MDArray struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"` // active, inactive
+	Level    string     `json:"level"` // raid1, raid5…
+	ReadOnly bool       `json:"read_only"`
+	Members  []MDMember `json:"members"`
+	Blocks   uint64     `json:"blocks"` // 1 KiB blocks
+	Total    int        `json:"total"`  // devices the array should have
+	Active   int        `json:"active"` // devices in sync
+	Status   string     `json:"status"` // e.g. "UU_", _ marks a missing device
+	Degraded bool       `json:"degraded"`
+	Sync     *MDSync    `json:"sync,omitempty"`
+}
+
+type MDMember struct {
+	Device string `json:"device"`
+	Role   int    `json:"role"`
+	Faulty bool   `json:"faulty"`
+	Spare  bool   `json:"spare"`
+}
+
+// MDSync is a running or pending resync, recovery, reshape or check.
+type MDSync struct {
+	Action  string  `json:"action"`
+	Percent float64 `json:"percent"`
+	Finish  string  `json:"finish,omitempty"` // kernel estimate, e.g. "1.2min"
+	Speed   string  `json:"speed,omitempty"`  // e.g. "12772K/sec"
+	Pending bool    `json:"pending"`          // DELAYED or PENDING
+}
+
+var (
+	reMDHeader = regexp.MustCompile(`^(md\S*) : (\S+)(.*)$`)
+	reMDMember = regexp.MustCompile(`^(\S+)\[(\d+)\]((?:\([A-Z]\))*)$`)
+	reMDCount  = regexp.MustCompile(`\[(\d+)/(\d+)\] \[([U_]+)\]`)
+	reMDSync   = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([\d.]+)%(?:.*finish=(\S+))?(?:.*speed=(\S+))?`)
+	reMDWait   = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*(DELAYED|PENDING)`)
+)
+
+// ParseMdstat parses /proc/mdstat.
+func ParseMdstat(content string) []MDArray {
+	var out []MDArray
+	var cur *MDArray
+	for _, line := range strings.Split(content, "\n") {
+		if m := reMDHeader.FindStringSubmatch(line); m != nil {
+			out = append(out, MDArray{Name: m[1], State: m[2], Members: []MDMember{}})
+			cur = &out[len(out)-1]
+			fields := strings.Fields(m[3])
+			for len(fields) > 0 && strings.HasPrefix(fields[0], "(") {
+				cur.ReadOnly = cur.ReadOnly || strings.Contains(fields[0], "read-only")
+				fields = fields[1:]
+			}
+			for _, f := range fields {
+				if mm := reMDMember.FindStringSubmatch(f); mm != nil {
+					role, _ := strconv.Atoi(mm[2])
+					cur.Members = append(cur.Members, MDMember{
+						Device: mm[1], Role: role,
+						Faulty: strings.Contains(mm[3], "(F)"),
+						Spare:  strings.Contains(mm[3], "(S)"),
+					})
+				} else if cur.Level == "" && !strings.Contains(f, "[") {
+					cur.Level = f
+				}
+			}
+			continue
+		}
+		if cur == nil || !strings.HasPrefix(line, " ") {
+			if strings.TrimSpace(line) == "" {
+				cur = nil
+			}
+			continue
+		}
+		if f := strings.Fields(line); len(f) > 1 && f[1] == "blocks" {
+			cur.Blocks, _ = strconv.ParseUint(f[0], 10, 64)
+		}
+		if m := reMDCount.FindStringSubmatch(line); m != nil {
+			cur.Total, _ = strconv.Atoi(m[1])
+			cur.Active, _ = strconv.Atoi(m[2])
+			cur.Status = m[3]
+			cur.Degraded = cur.Active < cur.Total
+		}
+		if m := reMDSync.FindStringSubmatch(line); m != nil {
+			pct, _ := strconv.ParseFloat(m[2], 64)
+			cur.Sync = &MDSync{Action: m[1], Percent: pct, Finish: m[3], Speed: m[4]}
+		} else if m := reMDWait.FindStringSubmatch(line); m != nil {
+			cur.Sync = &MDSync{Action: m[1], Pending: true}
+		}
+	}
+	for i := range out {
+		for _, m := range out[i].Members {
+			if m.Faulty {
+				out[i].Degraded = true
+			}
+		}
+	}
+	return out
+}
+
+// LVMGroup is one volume group from vgs.
+type LVMGroup struct {
+	Name    string `json:"name"`
+	Size    uint64 `json:"size"`
+	Free    uint64 `json:"free"`
+	PVs     int    `json:"pv_count"`
+	LVs     int    `json:"lv_count"`
+	Partial bool   `json:"partial"` // one or more physical volumes missing
+}
+
+// LVMVolume is one logical volume from lvs. For thin pools DataPercent
+// and MetadataPercent are what fills up; for thin volumes DataPercent is
+// their share of the pool in use.
+type LVMVolume struct {
+	VG              string  `json:"vg"`
+	Name            string  `json:"name"`
+	Attr            string  `json:"attr"`
+	Size            uint64  `json:"size"`
+	Pool            string  `json:"pool,omitempty"`
+	DataPercent     float64 `json:"data_percent"`
+	MetadataPercent float64 `json:"metadata_percent"`
+	ThinPool        bool    `json:"thin_pool"`
+	Partial         bool    `json:"partial"`
+}
+
+// vgsArgs and lvsArgs produce the output ParseVGs and ParseLVs expect.
+var (
+	vgsArgs = []string{"--noheadings", "--units", "b", "--nosuffix", "--separator", "|", "-o", "vg_name,vg_size,vg_free,pv_count,lv_count,vg_attr"}
+	lvsArgs = []string{"--noheadings", "--units", "b", "--nosuffix", "--separator", "|", "-o", "vg_name,lv_name,lv_attr,lv_size,pool_lv,data_percent,metadata_percent"}
+)
+
+func splitLVMLine(line string, n int) []string {
+	f := strings.Split(strings.TrimSpace(line), "|")
+	if len(f) < n {
+		return nil
+	}
+	for i := range f {
+		f[i] = strings.TrimSpace(f[i])
+	}
+	return f
+}
+
+func ParseVGs(out string) []LVMGroup {
+	var groups []LVMGroup
+	for _, line := range strings.Split(out, "\n") {
+		f := splitLVMLine(line, 6)
+		if f == nil {
+			continue
+		}
+		g := LVMGroup{Name: f[0]}
+		g.Size, _ = strconv.ParseUint(f[1], 10, 64)
+		g.Free, _ = strconv.ParseUint(f[2], 10, 64)
+		g.PVs, _ = strconv.Atoi(f[3])
+		g.LVs, _ = strconv.Atoi(f[4])
+		// vg_attr: wz--n-, 4th character p when a PV is missing
+		g.Partial = len(f[5]) > 3 && f[5][3] == 'p'
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func ParseLVs(out string) []LVMVolume {
+	var vols []LVMVolume
+	for _, line := range strings.Split(out, "\n") {
+		f := splitLVMLine(line, 7)
+		if f == nil {
+			continue
+		}
+		v := LVMVolume{VG: f[0], Name: f[1], Attr: f[2], Pool: f[4]}
+		v.Size, _ = strconv.ParseUint(f[3], 10, 64)
+		v.DataPercent, _ = strconv.ParseFloat(f[5], 64)
+		v.MetadataPercent, _ = strconv.ParseFloat(f[6], 64)
+		// lv_attr: 1st character t for thin pools, 9th p for partial
+		v.ThinPool = strings.HasPrefix(v.Attr, "t")
+		v.Partial = len(v.Attr) > 8 && v.Attr[8] == 'p'
+		vols = append(vols, v)
+	}
+	return vols
+}
+
+type ZFSPool struct {
+	Name   string  `json:"name"`
+	Size   uint64  `json:"size"`
+	Alloc  uint64  `json:"alloc"`
+	Free   uint64  `json:"free"`
+	Frag   float64 `json:"frag_percent"`
+	Cap    float64 `json:"cap_percent"`
+	Health string  `json:"health"` // ONLINE, DEGRADED, FAULTED, UNAVAIL…
+	Status string  `json:"status,omitempty"`
+	Scan   string  `json:"scan,omitempty"`
+	Errors string  `json:"errors,omitempty"`
+	// Devices lists every vdev and disk with its state, in status order
+	Devices []ZFSDevice `json:"devices"`
+}
+
+type ZFSDevice struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Depth int    `json:"depth"` // 0 is the pool itself
+}
+
+type ZFSDataset struct {
+	Name       string `json:"name"`
+	Used       uint64 `json:"used"`
+	Avail      uint64 `json:"avail"`
+	Refer      uint64 `json:"refer"`
+	Mountpoint string `json:"mountpoint"`
+}
+
+var (
+	zpoolListArgs = []string{"list", "-H", "-p", "-o", "name,size,alloc,free,frag,cap,health"}
+	zfsListArgs   = []string{"list", "-H", "-p", "-o", "name,used,avail,refer,mountpoint", "-t", "filesystem,volume"}
+)
+
+// ParseZpoolList parses `zpool list -H -p` with zpoolListArgs columns.
+func ParseZpoolList(out string) []ZFSPool {
+	var pools []ZFSPool
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) < 7 {
+			continue
+		}
+		p := ZFSPool{Name: f[0], Health: f[6], Devices: []ZFSDevice{}}
+		p.Size, _ = strconv.ParseUint(f[1], 10, 64)
+		p.Alloc, _ = strconv.ParseUint(f[2], 10, 64)
+		p.Free, _ = strconv.ParseUint(f[3], 10, 64)
+		p.Frag, _ = strconv.ParseFloat(strings.TrimSuffix(f[4], "%"), 64)
+		p.Cap, _ = strconv.ParseFloat(strings.TrimSuffix(f[5], "%"), 64)
+		pools = append(pools, p)
+	}
+	return pools
+}
+
+// ParseZpoolStatus parses `zpool status` for all pools, keyed by pool
+// name. Only Status, Scan, Errors and Devices are filled in.
+func ParseZpoolStatus(out string) map[string]*ZFSPool {
+	pools := map[string]*ZFSPool{}
+	var cur *ZFSPool
+	var field *string
+	inConfig := false
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		key, value, isKey := strings.Cut(trimmed, ":")
+		if isKey && !strings.Contains(key, " ") && !strings.HasPrefix(line, "\t") {
+			value = strings.TrimSpace(value)
+			field, inConfig = nil, false
+			if key == "pool" {
+				cur = &ZFSPool{Name: value, Devices: []ZFSDevice{}}
+				pools[value] = cur
+				continue
+			}
+			if cur == nil {
+				continue
+			}
+			switch key {
+			case "state":
+				cur.Health = value
+			case "status":
+				field = &cur.Status
+			case "scan":
+				field = &cur.Scan
+			case "errors":
+				field = &cur.Errors
+			case "config":
+				inConfig = true
+			}
+			if field != nil {
+				*field = value
+			}
+			continue
+		}
+		if cur == nil || trimmed == "" {
+			continue
+		}
+		if inConfig {
+			f := strings.Fields(trimmed)
+			if len(f) < 2 || f[0] == "NAME" {
+				continue
+			}
+			// config lines start with a tab, then two spaces per level
+			// below the pool
+			rest := strings.TrimPrefix(line, "\t")
+			depth := (len(rest) - len(strings.TrimLeft(rest, " "))) / 2
+			cur.Devices = append(cur.Devices, ZFSDevice{Name: f[0], State: f[1], Depth: depth})
+		} else if field != nil {
+			*field += " " + trimmed // continuation lines of status and scan
+		}
+	}
+	return pools
+}
+
+// ParseZFSList parses `zfs list -H -p` with zfsListArgs columns.
+func ParseZFSList(out string) []ZFSDataset {
+	var sets []ZFSDataset
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) < 5 {
+			continue
+		}
+		d := ZFSDataset{Name: f[0], Mountpoint: f[4]}
+		d.Used, _ = strconv.ParseUint(f[1], 10, 64)
+		d.Avail, _ = strconv.ParseUint(f[2], 10, 64)
+		d.Refer, _ = strconv.ParseUint(f[3], 10, 64)
+		sets = append(sets, d)
+	}
+	return sets
+}
+
+// runStorageTool runs name if it is installed, returning "" otherwise.
+func runStorageTool(name string, args ...string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func GetStorageStatus() StorageStatus {
+	s := StorageStatus{MD: []MDArray{}, VGs: []LVMGroup{}, LVs: []LVMVolume{}, Pools: []ZFSPool{}, Datasets: []ZFSDataset{}}
+	if data, err := os.ReadFile("/proc/mdstat"); err == nil {
+		s.MD = append(s.MD, ParseMdstat(string(data))...)
+	}
+	s.VGs = append(s.VGs, ParseVGs(runStorageTool("vgs", vgsArgs...))...)
+	s.LVs = append(s.LVs, ParseLVs(runStorageTool("lvs", lvsArgs...))...)
+	if out := runStorageTool("zpool", zpoolListArgs...); out != "" {
+		s.Pools = ParseZpoolList(out)
+		status := ParseZpoolStatus(runStorageTool("zpool", "status"))
+		for i := range s.Pools {
+			if st, ok := status[s.Pools[i].Name]; ok {
+				s.Pools[i].Status, s.Pools[i].Scan, s.Pools[i].Errors = st.Status, st.Scan, st.Errors
+				s.Pools[i].Devices = st.Devices
+			}
+		}
+		s.Datasets = append(s.Datasets, ParseZFSList(runStorageTool("zfs", zfsListArgs...))...)
+	}
+	return s
+}
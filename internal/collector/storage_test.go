@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseMdstat(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []MDArray
+	}{
+		{"mdstat_healthy", []MDArray{{
+			Name: "md0", State: "active", Level: "raid1",
+			Members: []MDMember{{Device: "sdb1", Role: 1}, {Device: "sda1", Role: 0}},
+			Blocks:  1047552, Total: 2, Active: 2, Status: "UU",
+		}}},
+		{"mdstat_degraded", []MDArray{
+			{
+				Name: "md1", State: "active", Level: "raid1",
+				Members: []MDMember{{Device: "sdb2", Role: 1, Faulty: true}, {Device: "sda2", Role: 0}},
+				Blocks:  976630464, Total: 2, Active: 1, Status: "U_", Degraded: true,
+			},
+			{
+				Name: "md2", State: "active", Level: "raid5",
+				Members: []MDMember{{Device: "sde1", Role: 3}, {Device: "sdd1", Role: 1}, {Device: "sdc1", Role: 0}},
+				Blocks:  2093056, Total: 3, Active: 2, Status: "UU_", Degraded: true,
+				Sync: &MDSync{Action: "recovery", Percent: 27.5, Finish: "1.2min", Speed: "10297K/sec"},
+			},
+		}},
+		{"mdstat_resync", []MDArray{
+			{
+				Name: "md0", State: "active", Level: "raid1",
+				Members: []MDMember{{Device: "sdb1", Role: 1}, {Device: "sda1", Role: 0}},
+				Blocks:  1953382400, Total: 2, Active: 2, Status: "UU",
+				Sync: &MDSync{Action: "resync", Percent: 12.6, Finish: "80.3min", Speed: "354125K/sec"},
+			},
+			{
+				Name: "md1", State: "active", Level: "raid1", ReadOnly: true,
+				Members: []MDMember{{Device: "sdd1", Role: 1}, {Device: "sdc1", Role: 0}},
+				Blocks:  1048512, Total: 2, Active: 2, Status: "UU",
+				Sync: &MDSync{Action: "resync", Pending: true},
+			},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			got := ParseMdstat(readFixture(t, tc.fixture))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseZpoolStatus(t *testing.T) {
+	pools := ParseZpoolStatus(readFixture(t, "zpool_status"))
+	tests := []struct {
+		name, health, status, scan string
+		devices                    []ZFSDevice
+	}{
+		{
+			"tank", "DEGRADED",
+			"One or more devices could not be used because the label is missing or invalid.  Sufficient replicas exist for the pool to continue functioning in a degraded state.",
+			"resilvered 1.20G in 00:01:02 with 0 errors on Sun Oct 18 10:00:00 2026",
+			[]ZFSDevice{
+				{Name: "tank", State: "DEGRADED", Depth: 0},
+				{Name: "mirror-0", State: "DEGRADED", Depth: 1},
+				{Name: "sda", State: "ONLINE", Depth: 2},
+				{Name: "12345678901234567890", State: "UNAVAIL", Depth: 2},
+			},
+		},
+		{
+			"backup", "FAULTED",
+			"One or more devices could not be opened.  There are insufficient replicas for the pool to continue functioning.",
+			"none requested",
+			[]ZFSDevice{
+				{Name: "backup", State: "UNAVAIL", Depth: 0},
+				{Name: "sdc", State: "UNAVAIL", Depth: 1},
+			},
+		},
+		{
+			"rpool", "ONLINE", "",
+			"scrub repaired 0B in 00:00:12 with 0 errors on Sun Oct 11 00:24:13 2026",
+			[]ZFSDevice{
+				{Name: "rpool", State: "ONLINE", Depth: 0},
+				{Name: "nvme0n1p3", State: "ONLINE", Depth: 1},
+			},
+		},
+	}
+	if len(pools) != len(tests) {
+		t.Fatalf("got %d pools, want %d", len(pools), len(tests))
+	}
+	for _, tc := range tests {
+		p := pools[tc.name]
+		if p == nil {
+			t.Errorf("pool %s missing", tc.name)
+			continue
+		}
+		if p.Health != tc.health || p.Status != tc.status || p.Scan != tc.scan || p.Errors != "No known data errors" {
+			t.Errorf("%s: got health %q status %q scan %q errors %q", tc.name, p.Health, p.Status, p.Scan, p.Errors)
+		}
+		if !reflect.DeepEqual(p.Devices, tc.devices) {
+			t.Errorf("%s devices: got %+v want %+v", tc.name, p.Devices, tc.devices)
+		}
+	}
+}
+
+func TestParseZpoolList(t *testing.T) {
+	got := ParseZpoolList(readFixture(t, "zpool_list"))
+	want := []ZFSPool{
+		{Name: "tank", Size: 1992864825344, Alloc: 1288490188800, Free: 704374636544, Frag: 12, Cap: 64, Health: "DEGRADED", Devices: []ZFSDevice{}},
+		{Name: "backup", Health: "UNAVAIL", Devices: []ZFSDevice{}},
+		{Name: "rpool", Size: 498216206336, Alloc: 107374182400, Free: 390842023936, Frag: 3, Cap: 21, Health: "ONLINE", Devices: []ZFSDevice{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseVGs(t *testing.T) {
+	got := ParseVGs(readFixture(t, "vgs"))
+	want := []LVMGroup{
+		{Name: "vg0", Size: 214744170496, Free: 10737418240, PVs: 1, LVs: 4},
+		{Name: "vg1", Size: 1000204886016, PVs: 2, LVs: 1, Partial: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseLVs(t *testing.T) {
+	got := ParseLVs(readFixture(t, "lvs_thin"))
+	want := []LVMVolume{
+		{VG: "vg0", Name: "root", Attr: "-wi-ao----", Size: 21474836480},
+		{VG: "vg0", Name: "pool0", Attr: "twi-aotz--", Size: 107374182400, DataPercent: 92.47, MetadataPercent: 12.10, ThinPool: true},
+		{VG: "vg0", Name: "thinvol1", Attr: "Vwi-aotz--", Size: 53687091200, Pool: "pool0", DataPercent: 85},
+		{VG: "vg0", Name: "pool1", Attr: "twi-aotz--", Size: 10737418240, DataPercent: 20, MetadataPercent: 95.5, ThinPool: true},
+		{VG: "vg1", Name: "data", Attr: "-wi-a---p-", Size: 1000204886016, Partial: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
@@ -35,12 +34,14 @@ type SystemInfo struct {
 }
 
 type CPUStats struct {
-	UsagePercent float64   `json:"usage_percent"`
-	PerCoreUsage []float64 `json:"per_core_usage"`
-	LoadAvg1     float64   `json:"load_avg_1"`
-	LoadAvg5     float64   `json:"load_avg_5"`
-	LoadAvg15    float64   `json:"load_avg_15"`
-	FrequencyMHz float64   `json:"frequency_mhz"`
+	UsagePercent float64        `json:"usage_percent"`
+	PerCoreUsage []float64      `json:"per_core_usage"`
+	Breakdown    CPUBreakdown   `json:"breakdown"`
+	PerCore      []CPUBreakdown `json:"per_core"`
+	LoadAvg1     float64        `json:"load_avg_1"`
+	LoadAvg5     float64        `json:"load_avg_5"`
+	LoadAvg15    float64        `json:"load_avg_15"`
+	FrequencyMHz float64        `json:"frequency_mhz"`
 }
 
 type MemoryStats struct {
@@ -67,6 +68,19 @@ type DiskPartition struct {
 	UsedPercent float64 `json:"used_percent"`
 	ReadBytes   uint64  `json:"read_bytes"`
 	WriteBytes  uint64  `json:"write_bytes"`
+
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+
+	Options    string   `json:"options"`    // mount options
+	FsOptions  string   `json:"fs_options"` // superblock options, e.g. errors=remount-ro
+	ReadOnly   bool     `json:"read_only"`
+	KernelName string   `json:"kernel_name"` // sda1, dm-0…
+	Disks      []string `json:"disks"`       // whole disks backing the device
+	Model      string   `json:"model"`
+	BindMounts []string `json:"bind_mounts"` // other mountpoints of the same filesystem, not counted again
 }
 
 type DiskStats struct {
@@ -104,6 +118,8 @@ type MetricsSnapshot struct {
 	Disk      DiskStats    `json:"disk"`
 	Network   NetworkStats `json:"network"`
 	Temps     []Temperature `json:"temperatures"`
+	Pressure  PressureStats `json:"pressure"`
+	DiskIO    []BlockIO     `json:"disk_io"`
 }
 
 // track previous network counters for speed calculation
@@ -154,14 +170,17 @@ func GetSystemInfo() SystemInfo {
 	return si
 }
 
-func GetCPUStats() CPUStats {
-	usage, _ := cpu.Percent(300*time.Millisecond, false)
-	perCore, _ := cpu.Percent(0, true)
+// GetCPUStats reports the CPU breakdown since the previous call of any
+// live caller. Recorders keep their own CPUSampler and use its Stats.
+func GetCPUStats() CPUStats { return defaultCPU.Stats() }
+
+func (s *CPUSampler) Stats() CPUStats {
+	overall, perCore := s.Sample()
 	lavg, _ := load.Avg()
 	cpuInfos, _ := cpu.Info()
 
-	stats := CPUStats{PerCoreUsage: perCore}
-	if len(usage) > 0 { stats.UsagePercent = usage[0] }
+	stats := CPUStats{UsagePercent: overall.Usage(), Breakdown: overall, PerCore: perCore, PerCoreUsage: make([]float64, len(perCore))}
+	for i, b := range perCore { stats.PerCoreUsage[i] = b.Usage() }
 	if lavg != nil {
 		stats.LoadAvg1 = lavg.Load1
 		stats.LoadAvg5 = lavg.Load5
@@ -188,36 +207,6 @@ func GetMemoryStats() MemoryStats {
 	return stats
 }
 
-func GetDiskStats() DiskStats {
-	parts, _ := disk.Partitions(false)
-	ios, _ := disk.IOCounters()
-	var partitions []DiskPartition
-	seen := map[string]bool{}
-	for _, p := range parts {
-		if seen[p.Mountpoint] { continue }
-		if !strings.HasPrefix(p.Mountpoint, "/") { continue }
-		u, err := disk.Usage(p.Mountpoint)
-		if err != nil || u.Total == 0 { continue }
-		seen[p.Mountpoint] = true
-		dp := DiskPartition{
-			Device: p.Device, Mountpoint: p.Mountpoint,
-			Fstype: p.Fstype, Total: u.Total,
-			Used: u.Used, Free: u.Free, UsedPercent: u.UsedPercent,
-		}
-		// match IO counter by device name
-		devName := p.Device
-		if idx := strings.LastIndex(devName, "/"); idx >= 0 {
-			devName = devName[idx+1:]
-		}
-		if io, ok := ios[devName]; ok {
-			dp.ReadBytes = io.ReadBytes
-			dp.WriteBytes = io.WriteBytes
-		}
-		partitions = append(partitions, dp)
-	}
-	return DiskStats{Partitions: partitions}
-}
-
 func isRealInterface(name string) bool {
 	// 1. 读 type 文件，非 Ethernet(1) 直接排除（sit=776, ip6tnl=769 等隧道）
 	typeBytes, err := os.ReadFile("/sys/class/net/" + name + "/type")
@@ -307,6 +296,44 @@ func GetNetworkStats() NetworkStats {
 	}
 }
 
+// InterfaceCounter is the cumulative transfer of one interface since boot
+// (or since the driver was last loaded).
+type InterfaceCounter struct {
+	Name      string
+	BytesRecv uint64
+	BytesSent uint64
+}
+
+// GetInterfaceCounters reads the counters of the named interfaces, or of
+// every physical interface when names is empty. Unlike GetNetworkStats it
+// does not disturb the speed calculation.
+func GetInterfaceCounters(names []string) ([]InterfaceCounter, error) {
+	ios, err := psnet.IOCounters(true)
+	if err != nil {
+		return nil, err
+	}
+	want := map[string]bool{}
+	for _, n := range names {
+		want[n] = true
+	}
+	var out []InterfaceCounter
+	for _, io := range ios {
+		if len(want) > 0 {
+			if !want[io.Name] { continue }
+		} else if !isRealInterface(io.Name) {
+			continue
+		}
+		out = append(out, InterfaceCounter{Name: io.Name, BytesRecv: io.BytesRecv, BytesSent: io.BytesSent})
+	}
+	return out, nil
+}
+
+// BootTime is the kernel boot time in unix seconds, 0 if unknown.
+func BootTime() uint64 {
+	t, _ := host.BootTime()
+	return t
+}
+
 func GetTemperatures() []Temperature {
 	var temps []Temperature
 	for i := 0; i < 10; i++ {
@@ -360,15 +387,19 @@ func GetCrontabs() []string {
 	return lines
 }
 
-func CollectAll() MetricsSnapshot {
+// CollectAll takes a snapshot with the CPU breakdown since the previous
+// Sample of sampler.
+func CollectAll(sampler *CPUSampler) MetricsSnapshot {
 	return MetricsSnapshot{
 		Timestamp: time.Now().Unix(),
 		System:    GetSystemInfo(),
-		CPU:       GetCPUStats(),
+		CPU:       sampler.Stats(),
 		Memory:    GetMemoryStats(),
 		Disk:      GetDiskStats(),
 		Network:   GetNetworkStats(),
 		Temps:     GetTemperatures(),
+		Pressure:  GetPressure(),
+		DiskIO:    GetDiskIO(),
 	}
 }
 
@@ -29,6 +29,8 @@ type SystemdService struct {
 	FragmentPath     string `json:"fragment_path"`
 	StartedAt        string `json:"started_at"`
 	TasksCurrent     string `json:"tasks"`
+	ControlGroup     string `json:"control_group"`
+	Pressure         *PressureStats `json:"pressure,omitempty"` // cgroup v2 only
 }
 
 func GetServices() ([]SystemdService, error) {
@@ -75,7 +77,7 @@ func enrichService(svc *SystemdService) {
 	props := []string{
 		"MainPID", "MemoryCurrent", "CPUUsageNSec",
 		"UnitFileState", "ExecStart", "FragmentPath",
-		"TasksCurrent", "ActiveEnterTimestamp",
+		"TasksCurrent", "ActiveEnterTimestamp", "ControlGroup",
 	}
 	args := append([]string{"show", svc.Unit, "--no-pager"}, propsArgs(props)...)
 	out, err := exec.CommandContext(ctx, "systemctl", args...).Output()
@@ -89,6 +91,10 @@ func enrichService(svc *SystemdService) {
 	svc.UnitFileState = kv["UnitFileState"]
 	svc.FragmentPath = kv["FragmentPath"]
 	svc.TasksCurrent = kv["TasksCurrent"]
+	svc.ControlGroup = kv["ControlGroup"]
+	if ps := GetCgroupPressure(svc.ControlGroup); ps.Available {
+		svc.Pressure = &ps
+	}
 
 	// ExecStart 只取路径部分
 	if es := kv["ExecStart"]; es != "" {
@@ -111,7 +117,7 @@ func enrichService(svc *SystemdService) {
 	}
 
 	// CPU 时间
-	if cpu := kv["CPUUsageNSec"]; cpu != "" && cpu != "[not set]" {
+	if cpu := kv["CPUUsageNSec"]; cpu != "" && cpu != "[not set]" && cpu != "18446744073709551615" {
 		if v, err := strconv.ParseUint(cpu, 10, 64); err == nil {
 			svc.CPUUsageNsec = v
 			svc.CPUFormatted = formatNsec(v)
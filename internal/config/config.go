@@ -2,26 +2,182 @@ package config
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Listen          string        `yaml:"listen"`
-	DBPath          string        `yaml:"db_path"`
-	CollectInterval time.Duration `yaml:"collect_interval"`
-	JWTSecret       string        `yaml:"jwt_secret"`
-	Username        string        `yaml:"username"`
-	Password        string        `yaml:"password"` // plain text
-	Alert           AlertConfig   `yaml:"alert"`
+	Listen          string             `yaml:"listen"`
+	DBPath          string             `yaml:"db_path"`
+	CollectInterval time.Duration      `yaml:"collect_interval"`
+	JWTSecret       string             `yaml:"jwt_secret"`
+	Username        string             `yaml:"username"`
+	Password        string             `yaml:"password"` // plain text
+	Alert           AlertConfig        `yaml:"alert"`
+	Storage         StorageConfig      `yaml:"storage"`
+	Sinks           SinksConfig        `yaml:"sinks"`
+	History         HistoryConfig      `yaml:"history"`
+	Traffic         TrafficConfig      `yaml:"traffic"`
+	Watchdog        WatchdogConfig     `yaml:"watchdog"`
+	KernelEvents    KernelEventsConfig `yaml:"kernel_events"`
+}
+
+// KernelEventsConfig follows the kernel log for OOM kills, segfaults, I/O
+// errors and filesystems remounted read-only.
+type KernelEventsConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	AlertTypes []string `yaml:"alert_types"` // oom_kill, segfault, io_error, fs_readonly
+}
+
+// WatchdogConfig lists processes that must be running, typically daemons
+// not managed by systemd. The list is also editable through the API, but
+// restart commands run as GoPanel's user, so the API may only set them
+// when AllowAPICommands is on.
+type WatchdogConfig struct {
+	Interval         time.Duration  `yaml:"interval"`
+	AllowAPICommands bool           `yaml:"allow_api_commands"`
+	Watches          []ProcessWatch `yaml:"watches"`
+}
+
+type ProcessWatch struct {
+	Name           string `yaml:"name" json:"name"`                       // unique label
+	Match          string `yaml:"match" json:"match"`                     // regexp on process name or command line
+	MinCount       int    `yaml:"min_count" json:"min_count"`             // default 1
+	RestartCommand string `yaml:"restart_command" json:"restart_command"` // run with sh -c while missing, optional
+}
+
+// TrafficConfig accumulates per-interface transfer into daily buckets that
+// survive reboots, for VPS plans with a monthly transfer cap.
+type TrafficConfig struct {
+	Enabled        bool      `yaml:"enabled"`
+	Interfaces     []string  `yaml:"interfaces"`      // empty = every physical interface
+	CycleStartDay  int       `yaml:"cycle_start_day"` // day of month the billing cycle resets, 1-28
+	QuotaGB        float64   `yaml:"quota_gb"`        // per cycle, 0 = no quota
+	QuotaDirection string    `yaml:"quota_direction"` // both (default), tx or rx: what the provider bills
+	AlertPercent   []float64 `yaml:"alert_percent"`   // quota usage that triggers an alert, once per cycle each
+}
+
+// HistoryConfig selects optional per-object history beyond host metrics.
+type HistoryConfig struct {
+	Services    ServiceHistoryConfig    `yaml:"services"`
+	Processes   ProcessHistoryConfig    `yaml:"processes"`
+	Connections ConnectionHistoryConfig `yaml:"connections"`
+	DiskIO      DiskIOHistoryConfig     `yaml:"disk_io"`
+}
+
+type ServiceHistoryConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Units   []string `yaml:"units"` // empty = every active unit
+}
+
+// ProcessHistoryConfig records the top consumers periodically so incidents
+// can be investigated after the fact.
+type ProcessHistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval"`
+	TopN      int           `yaml:"top_n"`     // by CPU and by memory, merged
+	Retention time.Duration `yaml:"retention"` // usually shorter than storage.retention
+}
+
+// ConnectionHistoryConfig records TCP socket counts per state, which is
+// where SYN floods and connection leaks show up.
+type ConnectionHistoryConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DiskIOHistoryConfig records per block device throughput, IOPS, await
+// and utilisation, averaged over each interval.
+type DiskIOHistoryConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type StorageConfig struct {
+	Backend       string        `yaml:"backend"`        // sqlite (default) or memory
+	MemoryPoints  int           `yaml:"memory_points"`  // samples kept by the memory backend
+	FlushInterval time.Duration `yaml:"flush_interval"` // sqlite: buffer samples and write them in one transaction per interval, 0 = write through
+	Retention     time.Duration `yaml:"retention"`      // history older than this is pruned hourly
 }
 
 type AlertConfig struct {
-	CPU     float64 `yaml:"cpu"`
-	Memory  float64 `yaml:"memory"`
-	Disk    float64 `yaml:"disk"`
-	Webhook string  `yaml:"webhook"`
+	CPU       float64             `yaml:"cpu"`
+	Memory    float64             `yaml:"memory"`
+	Disk      float64             `yaml:"disk"`
+	Inodes    float64             `yaml:"inodes"` // inode usage percent per filesystem
+	Webhook   string              `yaml:"webhook"`
+	Anomaly   AnomalyConfig       `yaml:"anomaly"`
+	Listeners ListenerAlertConfig `yaml:"listeners"`
+	Pressure  PressureAlertConfig `yaml:"pressure"`
+	Storage   StorageAlertConfig  `yaml:"storage"`
+}
+
+// StorageAlertConfig alerts on degraded md arrays, missing LVM physical
+// volumes, full thin pools and ZFS pools that are not ONLINE.
+type StorageAlertConfig struct {
+	Enabled         bool    `yaml:"enabled"`
+	ThinPoolPercent float64 `yaml:"thin_pool_percent"` // data or metadata usage, 0 = off
+}
+
+// PressureAlertConfig alerts on PSI "some" avg60, the percentage of the
+// last minute in which tasks were stalled waiting for the resource.
+// 0 disables the check.
+type PressureAlertConfig struct {
+	CPU    float64 `yaml:"cpu"`
+	Memory float64 `yaml:"memory"`
+	IO     float64 `yaml:"io"`
+}
+
+// ListenerAlertConfig alerts when a new public listening socket appears.
+// With an empty Allowed list, whatever is listening at startup is taken as
+// expected; otherwise anything not allowed is reported.
+type ListenerAlertConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Allowed []string `yaml:"allowed"` // "22", "tcp/443", "udp/53" or a process name such as "sshd"
+}
+
+// AnomalyConfig fires an alert when a metric stays far from its usual
+// value for this hour of the week, e.g. 40% CPU at 3am when it is
+// normally 2%.
+type AnomalyConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Metrics  []string      `yaml:"metrics"`   // cpu, memory, disk, cpu_pressure, memory_pressure, io_pressure, cpu_iowait, cpu_steal
+	ZScore   float64       `yaml:"z_score"`   // |value-mean|/stddev that counts as a deviation
+	Sustain  time.Duration `yaml:"sustain"`   // how long the deviation must persist
+	Window   time.Duration `yaml:"window"`    // history used to build the baseline
+	MinWeeks int           `yaml:"min_weeks"` // weeks of data an hour-of-week bucket needs before it is trusted
+}
+
+// SinksConfig controls forwarding of collected snapshots to remote
+// time-series backends in addition to the local SQLite history.
+type SinksConfig struct {
+	BatchSize      int                `yaml:"batch_size"`
+	FlushInterval  time.Duration      `yaml:"flush_interval"`
+	BufferDir      string             `yaml:"buffer_dir"`       // on-disk spool used while a sink is unreachable
+	MaxBufferBytes int64              `yaml:"max_buffer_bytes"` // per sink, 0 = unlimited
+	Influx         InfluxSinkConfig   `yaml:"influx"`
+	Graphite       GraphiteSinkConfig `yaml:"graphite"`
+	JSON           JSONSinkConfig     `yaml:"json"`
+}
+
+type InfluxSinkConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	URL     string `yaml:"url"`   // v1: http://host:8086/write?db=gopanel  v2: http://host:8086/api/v2/write?org=o&bucket=b
+	Token   string `yaml:"token"` // optional, sent as "Authorization: Token ..."
+}
+
+type GraphiteSinkConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"` // host:2003
+	Prefix  string `yaml:"prefix"`
+}
+
+type JSONSinkConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
 }
 
 func Default() *Config {
@@ -32,7 +188,53 @@ func Default() *Config {
 		JWTSecret:       "gopanel-change-me",
 		Username:        "admin",
 		Password:        "admin",
-		Alert: AlertConfig{CPU: 90, Memory: 90, Disk: 90},
+		Alert: AlertConfig{
+			CPU: 90, Memory: 90, Disk: 90, Inodes: 90,
+			Storage: StorageAlertConfig{Enabled: true, ThinPoolPercent: 90},
+			Anomaly: AnomalyConfig{
+				Metrics:  []string{"cpu", "memory"},
+				ZScore:   4,
+				Sustain:  5 * time.Minute,
+				Window:   28 * 24 * time.Hour,
+				MinWeeks: 3,
+			},
+			Listeners: ListenerAlertConfig{Enabled: true},
+		},
+		Storage: StorageConfig{
+			Backend:       "sqlite",
+			MemoryPoints:  17280,
+			FlushInterval: 30 * time.Second,
+			Retention:     7 * 24 * time.Hour,
+		},
+		History: HistoryConfig{
+			Services: ServiceHistoryConfig{Enabled: true},
+			Processes: ProcessHistoryConfig{
+				Enabled:   true,
+				Interval:  time.Minute,
+				TopN:      10,
+				Retention: 72 * time.Hour,
+			},
+			Connections: ConnectionHistoryConfig{Enabled: true, Interval: time.Minute},
+			DiskIO:      DiskIOHistoryConfig{Enabled: true, Interval: time.Minute},
+		},
+		Traffic: TrafficConfig{
+			Enabled:        true,
+			CycleStartDay:  1,
+			QuotaDirection: "both",
+			AlertPercent:   []float64{80, 100},
+		},
+		Watchdog: WatchdogConfig{Interval: 30 * time.Second},
+		KernelEvents: KernelEventsConfig{
+			Enabled:    true,
+			AlertTypes: []string{"oom_kill", "io_error", "fs_readonly"},
+		},
+		Sinks: SinksConfig{
+			BatchSize:      50,
+			FlushInterval:  10 * time.Second,
+			BufferDir:      "sink-buffer",
+			MaxBufferBytes: 16 << 20,
+			Graphite:       GraphiteSinkConfig{Prefix: "gopanel"},
+		},
 	}
 }
 
@@ -45,6 +247,25 @@ func Load(path string) (*Config, error) {
 	return cfg, yaml.Unmarshal(data, cfg)
 }
 
+// mu serialises changes to the live config with writing it to disk, so a
+// handler saving the file never reads a struct another one is modifying.
+var mu sync.Mutex
+
+// Update applies fn to c under the config lock and then, if path is set,
+// saves the result. A nil fn only saves.
+func (c *Config) Update(path string, fn func(*Config)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if fn != nil {
+		fn(c)
+	}
+	if path == "" {
+		return nil
+	}
+	return c.Save(path)
+}
+
+// Save writes c to path. Callers that share c use Update instead.
 func (c *Config) Save(path string) error {
 	data, err := yaml.Marshal(c)
 	if err != nil {
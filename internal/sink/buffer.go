@@ -0,0 +1,120 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// diskBuffer spools batches that could not be delivered as JSON lines in
+// <dir>/<sink>.buf and replays them once the sink is reachable again.
+type diskBuffer struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+}
+
+func newDiskBuffer(dir, name string, maxBytes int64) *diskBuffer {
+	if dir == "" {
+		return &diskBuffer{}
+	}
+	return &diskBuffer{path: filepath.Join(dir, name+".buf"), maxBytes: maxBytes}
+}
+
+func (b *diskBuffer) append(points []Point) error {
+	if b.path == "" {
+		return fmt.Errorf("no buffer_dir configured, dropped %d points", len(points))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	line, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	if b.maxBytes > 0 {
+		if fi, err := os.Stat(b.path); err == nil && fi.Size()+int64(len(line)) > b.maxBytes {
+			return fmt.Errorf("buffer full (%d bytes), dropped %d points", fi.Size(), len(points))
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// replay sends spooled batches oldest first. write reports how many
+// leading points of a batch it delivered or dropped; on failure the
+// undelivered remainder is written back and the error returned.
+func (b *diskBuffer) replay(write func([]Point) (int, error)) error {
+	if b.path == "" {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var lines [][]byte
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		lines = append(lines, append([]byte(nil), sc.Bytes()...))
+	}
+	f.Close()
+
+	for i, line := range lines {
+		var points []Point
+		if err := json.Unmarshal(line, &points); err != nil {
+			continue // corrupt line, skip it
+		}
+		n, err := write(points)
+		if err == nil {
+			continue
+		}
+		if n == len(points) {
+			log.Printf("sink buffer %s: dropped spooled points: %v", filepath.Base(b.path), err)
+			continue
+		}
+		rest := lines[i:]
+		if n > 0 {
+			if line, err := json.Marshal(points[n:]); err == nil {
+				rest = append([][]byte{line}, lines[i+1:]...)
+			}
+		}
+		return b.rewrite(rest, err)
+	}
+	return os.Remove(b.path)
+}
+
+func (b *diskBuffer) rewrite(lines [][]byte, cause error) error {
+	tmp := b.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return cause
+	}
+	w := bufio.NewWriter(f)
+	for _, l := range lines {
+		w.Write(l)
+		w.WriteByte('\n')
+	}
+	w.Flush()
+	f.Close()
+	os.Rename(tmp, b.path)
+	return cause
+}
@@ -0,0 +1,278 @@
+package sink
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// Point is a single measurement row as understood by line-protocol style
+// backends: measurement + tags + numeric fields at a unix timestamp (seconds).
+type Point struct {
+	Measurement string             `json:"measurement"`
+	Tags        map[string]string  `json:"tags,omitempty"`
+	Fields      map[string]float64 `json:"fields"`
+	Timestamp   int64              `json:"timestamp"`
+}
+
+// Sink writes a batch of points to a remote backend.
+type Sink interface {
+	Name() string
+	Write(points []Point) error
+}
+
+type output struct {
+	sink     Sink
+	queue    chan []Point
+	buf      *diskBuffer
+	batch    int
+	interval time.Duration
+}
+
+// Manager fans snapshots out to every enabled sink. Each sink has its own
+// queue and goroutine so a slow or unreachable backend never blocks the others
+// or the collector loop.
+type Manager struct {
+	outputs []*output
+}
+
+func NewManager(cfg config.SinksConfig) *Manager {
+	var sinks []Sink
+	if cfg.Influx.Enabled && cfg.Influx.URL != "" {
+		sinks = append(sinks, NewInflux(cfg.Influx.URL, cfg.Influx.Token))
+	}
+	if cfg.Graphite.Enabled && cfg.Graphite.Addr != "" {
+		sinks = append(sinks, NewGraphite(cfg.Graphite.Addr, cfg.Graphite.Prefix))
+	}
+	if cfg.JSON.Enabled && cfg.JSON.URL != "" {
+		sinks = append(sinks, NewJSON(cfg.JSON.URL, cfg.JSON.Headers))
+	}
+
+	batch := cfg.BatchSize
+	if batch <= 0 {
+		batch = 50
+	}
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	m := &Manager{}
+	for _, s := range sinks {
+		m.outputs = append(m.outputs, &output{
+			sink:     s,
+			queue:    make(chan []Point, 64),
+			buf:      newDiskBuffer(cfg.BufferDir, s.Name(), cfg.MaxBufferBytes),
+			batch:    batch,
+			interval: interval,
+		})
+	}
+	return m
+}
+
+// Start launches one worker per enabled sink.
+func (m *Manager) Start() {
+	for _, o := range m.outputs {
+		log.Printf("metrics sink enabled: %s", o.sink.Name())
+		go o.run()
+	}
+}
+
+// Publish converts a snapshot to points and queues it for every sink.
+// It never blocks; if a queue is full the snapshot is dropped for that sink.
+func (m *Manager) Publish(snap collector.MetricsSnapshot) {
+	if m == nil || len(m.outputs) == 0 {
+		return
+	}
+	points := SnapshotPoints(snap)
+	for _, o := range m.outputs {
+		select {
+		case o.queue <- points:
+		default:
+		}
+	}
+}
+
+func (o *output) run() {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	var pending []Point
+	for {
+		select {
+		case pts := <-o.queue:
+			pending = append(pending, pts...)
+			if len(pending) < o.batch {
+				continue
+			}
+		case <-ticker.C:
+		}
+		o.flush(pending)
+		pending = nil
+	}
+}
+
+// flush replays anything spooled on disk first so the backend receives
+// points in order, then writes the current batch. Points that could not
+// be delivered are spooled unless the backend rejected them outright.
+func (o *output) flush(pending []Point) {
+	write := func(points []Point) (int, error) { return writeSplitting(o.sink, points) }
+	if err := o.buf.replay(write); err != nil {
+		log.Printf("sink %s: %v", o.sink.Name(), err)
+		if len(pending) > 0 {
+			o.spool(pending)
+		}
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if n, err := write(pending); err != nil {
+		log.Printf("sink %s: %v", o.sink.Name(), err)
+		if n < len(pending) {
+			o.spool(pending[n:])
+		}
+	}
+}
+
+// writeSplitting writes points and returns how many leading points were
+// dealt with, delivered or dropped; the rest are still to be sent. A batch
+// refused as too large is halved until the parts fit, so only a single
+// point the backend still refuses is lost.
+func writeSplitting(s Sink, points []Point) (int, error) {
+	err := s.Write(points)
+	var rejected *RejectedError
+	if errors.As(err, &rejected) && rejected.TooLarge && len(points) > 1 {
+		mid := len(points) / 2
+		n, err := writeSplitting(s, points[:mid])
+		if n < mid {
+			return n, err
+		}
+		m, err2 := writeSplitting(s, points[mid:])
+		if err2 == nil {
+			err2 = err // a point dropped from the first half
+		}
+		return mid + m, err2
+	}
+	if err != nil && !IsRejected(err) {
+		return 0, err
+	}
+	return len(points), err
+}
+
+func (o *output) spool(points []Point) {
+	if err := o.buf.append(points); err != nil {
+		log.Printf("sink %s: buffer: %v", o.sink.Name(), err)
+	}
+}
+
+// SnapshotPoints flattens a snapshot into host-level, per-partition and
+// per-interface points tagged with the hostname.
+func SnapshotPoints(snap collector.MetricsSnapshot) []Point {
+	host := snap.System.Hostname
+	ts := snap.Timestamp
+	points := []Point{
+		{
+			Measurement: "cpu",
+			Tags:        map[string]string{"host": host},
+			Fields: map[string]float64{
+				"usage_percent": snap.CPU.UsagePercent,
+				"load1":         snap.CPU.LoadAvg1,
+				"load5":         snap.CPU.LoadAvg5,
+				"load15":        snap.CPU.LoadAvg15,
+				"user":          snap.CPU.Breakdown.User,
+				"system":        snap.CPU.Breakdown.System,
+				"nice":          snap.CPU.Breakdown.Nice,
+				"iowait":        snap.CPU.Breakdown.Iowait,
+				"irq":           snap.CPU.Breakdown.Irq,
+				"softirq":       snap.CPU.Breakdown.Softirq,
+				"steal":         snap.CPU.Breakdown.Steal,
+				"guest":         snap.CPU.Breakdown.Guest,
+			},
+			Timestamp: ts,
+		},
+		{
+			Measurement: "memory",
+			Tags:        map[string]string{"host": host},
+			Fields: map[string]float64{
+				"used":         float64(snap.Memory.Used),
+				"available":    float64(snap.Memory.Available),
+				"used_percent": snap.Memory.UsedPercent,
+				"swap_used":    float64(snap.Memory.SwapUsed),
+				"swap_percent": snap.Memory.SwapPercent,
+			},
+			Timestamp: ts,
+		},
+	}
+	if psi := snap.Pressure; psi.Available {
+		for _, r := range []struct {
+			name string
+			p    collector.Pressure
+		}{{"cpu", psi.CPU}, {"memory", psi.Memory}, {"io", psi.IO}} {
+			points = append(points, Point{
+				Measurement: "pressure",
+				Tags:        map[string]string{"host": host, "resource": r.name},
+				Fields: map[string]float64{
+					"some_avg10": r.p.Some.Avg10,
+					"some_avg60": r.p.Some.Avg60,
+					"full_avg10": r.p.Full.Avg10,
+					"full_avg60": r.p.Full.Avg60,
+				},
+				Timestamp: ts,
+			})
+		}
+	}
+	for _, p := range snap.Disk.Partitions {
+		points = append(points, Point{
+			Measurement: "disk",
+			Tags:        map[string]string{"host": host, "mountpoint": p.Mountpoint, "device": p.Device},
+			Fields: map[string]float64{
+				"used":         float64(p.Used),
+				"free":         float64(p.Free),
+				"used_percent": p.UsedPercent,
+
+				"inodes_used":         float64(p.InodesUsed),
+				"inodes_used_percent": p.InodesUsedPercent,
+			},
+			Timestamp: ts,
+		})
+	}
+	for _, b := range snap.DiskIO {
+		points = append(points, Point{
+			Measurement: "diskio",
+			Tags:        map[string]string{"host": host, "device": b.Name},
+			Fields: map[string]float64{
+				"read_bps":   b.ReadBps,
+				"write_bps":  b.WriteBps,
+				"read_iops":  b.ReadIOPS,
+				"write_iops": b.WriteIOPS,
+				"await_ms":   b.Await,
+				"util":       b.Util,
+			},
+			Timestamp: ts,
+		})
+	}
+	for _, iface := range snap.Network.Interfaces {
+		points = append(points, Point{
+			Measurement: "net",
+			Tags:        map[string]string{"host": host, "interface": iface.Name},
+			Fields: map[string]float64{
+				"bytes_recv": float64(iface.BytesRecv),
+				"bytes_sent": float64(iface.BytesSent),
+				"speed_down": float64(iface.SpeedDown),
+				"speed_up":   float64(iface.SpeedUp),
+			},
+			Timestamp: ts,
+		})
+	}
+	for _, t := range snap.Temps {
+		points = append(points, Point{
+			Measurement: "temperature",
+			Tags:        map[string]string{"host": host, "sensor": t.Sensor},
+			Fields:      map[string]float64{"celsius": t.Temp},
+			Timestamp:   ts,
+		})
+	}
+	return points
+}
@@ -0,0 +1,430 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPoints = []Point{
+	{
+		Measurement: "cpu",
+		Tags:        map[string]string{"host": "web 1"},
+		Fields:      map[string]float64{"usage_percent": 12.5, "load1": 0.25},
+		Timestamp:   1700000000,
+	},
+	{
+		Measurement: "disk",
+		Tags:        map[string]string{"host": "web 1", "mountpoint": "/var/lib", "device": "/dev/sda1"},
+		Fields:      map[string]float64{"used_percent": 40},
+		Timestamp:   1700000000,
+	},
+}
+
+func TestEncodeLineProtocol(t *testing.T) {
+	want := "cpu,host=web\\ 1 load1=0.25,usage_percent=12.5 1700000000\n" +
+		"disk,device=/dev/sda1,host=web\\ 1,mountpoint=/var/lib used_percent=40 1700000000\n"
+	if got := string(EncodeLineProtocol(testPoints)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEncodeGraphite(t *testing.T) {
+	want := "gp.web_1.cpu.load1 0.25 1700000000\n" +
+		"gp.web_1.cpu.usage_percent 12.5 1700000000\n" +
+		"gp.web_1.disk.var_lib.used_percent 40 1700000000\n"
+	if got := string(EncodeGraphite("gp", testPoints)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// receiver is a stand-in HTTP backend that records request bodies and
+// answers with the queued status codes, then 204.
+type receiver struct {
+	mu       sync.Mutex
+	bodies   []string
+	headers  []http.Header
+	queries  []string
+	statuses []int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header.Clone())
+	r.queries = append(r.queries, req.URL.RawQuery)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestInfluxWrite(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	if err := NewInflux(srv.URL+"/api/v2/write?bucket=b", "secret").Write(testPoints); err != nil {
+		t.Fatal(err)
+	}
+	if got := rcv.bodies[0]; got != string(EncodeLineProtocol(testPoints)) {
+		t.Errorf("body = %q", got)
+	}
+	if got := rcv.headers[0].Get("Authorization"); got != "Token secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if !strings.Contains(rcv.queries[0], "precision=s") || !strings.Contains(rcv.queries[0], "bucket=b") {
+		t.Errorf("query = %q", rcv.queries[0])
+	}
+}
+
+func TestJSONWrite(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	if err := NewJSON(srv.URL, map[string]string{"X-Key": "k"}).Write(testPoints); err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Points []Point }
+	if err := json.Unmarshal([]byte(rcv.bodies[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Points) != 2 || got.Points[1].Tags["mountpoint"] != "/var/lib" || got.Points[0].Fields["usage_percent"] != 12.5 {
+		t.Errorf("points = %+v", got.Points)
+	}
+	if rcv.headers[0].Get("X-Key") != "k" || rcv.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", rcv.headers[0])
+	}
+}
+
+func TestGraphiteWrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		lines <- got
+	}()
+
+	if err := NewGraphite(ln.Addr().String(), ".gp.").Write(testPoints); err != nil {
+		t.Fatal(err)
+	}
+	got := <-lines
+	if len(got) != 3 || got[0] != "gp.web_1.cpu.load1 0.25 1700000000" {
+		t.Errorf("lines = %q", got)
+	}
+}
+
+func TestDoRequestClassifiesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusRequestEntityTooLarge, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		rcv := &receiver{statuses: []int{tc.status}}
+		srv := httptest.NewServer(rcv)
+		err := NewJSON(srv.URL, nil).Write(testPoints)
+		srv.Close()
+		if err == nil {
+			t.Errorf("%d: no error", tc.status)
+			continue
+		}
+		if IsRejected(err) != tc.rejected {
+			t.Errorf("%d: IsRejected = %v, want %v", tc.status, !tc.rejected, tc.rejected)
+		}
+	}
+}
+
+// fakeSink records batches and returns err instead while it is set.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]Point
+	err     error
+}
+
+func (s *fakeSink) Name() string { return "fake" }
+
+func (s *fakeSink) Write(points []Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, points)
+	return nil
+}
+
+func (s *fakeSink) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *fakeSink) written() [][]Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]Point(nil), s.batches...)
+}
+
+func point(ts int64) Point {
+	return Point{Measurement: "cpu", Fields: map[string]float64{"v": float64(ts)}, Timestamp: ts}
+}
+
+func TestOutputBatches(t *testing.T) {
+	fs := &fakeSink{}
+	o := &output{sink: fs, queue: make(chan []Point, 8), buf: newDiskBuffer("", "fake", 0), batch: 3, interval: time.Hour}
+	go o.run()
+
+	for ts := int64(1); ts <= 4; ts++ {
+		o.queue <- []Point{point(ts)}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(fs.written()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := fs.written()
+	if len(got) != 1 || len(got[0]) != 3 || got[0][2].Timestamp != 3 {
+		t.Fatalf("batches = %+v", got)
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+	fs := &fakeSink{err: errors.New("connection refused")}
+	o := &output{sink: fs, buf: newDiskBuffer(dir, "fake", 0)}
+
+	o.flush([]Point{point(1)})
+	o.flush([]Point{point(2)})
+	if len(fs.written()) != 0 {
+		t.Fatal("wrote while down")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fake.buf")); err != nil {
+		t.Fatalf("no spool file: %v", err)
+	}
+
+	fs.setErr(nil)
+	o.flush([]Point{point(3)})
+	got := fs.written()
+	if len(got) != 3 {
+		t.Fatalf("batches = %+v", got)
+	}
+	for i, b := range got {
+		if b[0].Timestamp != int64(i+1) {
+			t.Errorf("batch %d has timestamp %d, want in order", i, b[0].Timestamp)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fake.buf")); !os.IsNotExist(err) {
+		t.Errorf("spool file left after replay: %v", err)
+	}
+}
+
+func TestSpoolDropsRejectedBatch(t *testing.T) {
+	dir := t.TempDir()
+	buf := newDiskBuffer(dir, "fake", 0)
+	for ts := int64(1); ts <= 3; ts++ {
+		if err := buf.append([]Point{point(ts)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var delivered []int64
+	err := buf.replay(func(points []Point) (int, error) {
+		if points[0].Timestamp == 1 {
+			return len(points), &RejectedError{Err: errors.New("400 Bad Request")}
+		}
+		delivered = append(delivered, points[0].Timestamp)
+		return len(points), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 2 || delivered[0] != 2 || delivered[1] != 3 {
+		t.Errorf("delivered = %v, want [2 3]", delivered)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fake.buf")); !os.IsNotExist(err) {
+		t.Errorf("spool file left after replay: %v", err)
+	}
+}
+
+func TestSpoolKeepsRemainderOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	buf := newDiskBuffer(dir, "fake", 0)
+	for ts := int64(1); ts <= 3; ts++ {
+		buf.append([]Point{point(ts)})
+	}
+
+	calls := 0
+	err := buf.replay(func(points []Point) (int, error) {
+		calls++
+		if points[0].Timestamp == 2 {
+			return 0, errors.New("timeout")
+		}
+		return len(points), nil
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+
+	var rest []int64
+	buf.replay(func(points []Point) (int, error) {
+		rest = append(rest, points[0].Timestamp)
+		return len(points), nil
+	})
+	if len(rest) != 2 || rest[0] != 2 || rest[1] != 3 {
+		t.Errorf("remaining = %v, want [2 3]", rest)
+	}
+}
+
+func TestFlushDoesNotSpoolRejected(t *testing.T) {
+	dir := t.TempDir()
+	fs := &fakeSink{err: &RejectedError{Err: errors.New("400")}}
+	o := &output{sink: fs, buf: newDiskBuffer(dir, "fake", 0)}
+	o.flush([]Point{point(1)})
+	if _, err := os.Stat(filepath.Join(dir, "fake.buf")); !os.IsNotExist(err) {
+		t.Errorf("rejected batch was spooled: %v", err)
+	}
+}
+
+// sizeLimitedSink accepts batches of up to max points and answers 413 to
+// larger ones and to any batch containing the point at huge; down makes
+// every write fail with a plain error.
+type sizeLimitedSink struct {
+	fakeSink
+	max  int
+	huge int64
+	down bool
+}
+
+func (s *sizeLimitedSink) Write(points []Point) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	tooLarge := len(points) > s.max
+	for _, p := range points {
+		tooLarge = tooLarge || p.Timestamp == s.huge
+	}
+	if tooLarge {
+		return &RejectedError{Err: errors.New("413 Request Entity Too Large"), TooLarge: true}
+	}
+	return s.fakeSink.Write(points)
+}
+
+func TestWriteSplittingOnTooLarge(t *testing.T) {
+	fs := &sizeLimitedSink{max: 2, huge: 5}
+	var points []Point
+	for ts := int64(1); ts <= 7; ts++ {
+		points = append(points, point(ts))
+	}
+	n, err := writeSplitting(fs, points)
+	if n != len(points) || !IsRejected(err) {
+		t.Fatalf("n = %d, err = %v; want all handled and the huge point rejected", n, err)
+	}
+	var got []int64
+	for _, b := range fs.written() {
+		if len(b) > fs.max {
+			t.Errorf("batch of %d written", len(b))
+		}
+		for _, p := range b {
+			got = append(got, p.Timestamp)
+		}
+	}
+	want := []int64{1, 2, 3, 4, 6, 7}
+	if len(got) != len(want) {
+		t.Fatalf("delivered %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("delivered %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFlushSpoolsUnsentHalf(t *testing.T) {
+	dir := t.TempDir()
+	fs := &sizeLimitedSink{max: 2, huge: -1}
+	o := &output{sink: fs, buf: newDiskBuffer(dir, "fake", 0)}
+
+	// the first half fits, then the backend goes away
+	calls := 0
+	o.sink = sinkFunc(func(points []Point) error {
+		calls++
+		if calls > 2 {
+			fs.down = true
+		}
+		return fs.Write(points)
+	})
+	o.flush([]Point{point(1), point(2), point(3), point(4)})
+	if got := fs.written(); len(got) != 1 || got[0][0].Timestamp != 1 {
+		t.Fatalf("written = %+v, want the first half", got)
+	}
+
+	var spooled []int64
+	o.buf.replay(func(points []Point) (int, error) {
+		for _, p := range points {
+			spooled = append(spooled, p.Timestamp)
+		}
+		return len(points), nil
+	})
+	if len(spooled) != 2 || spooled[0] != 3 || spooled[1] != 4 {
+		t.Errorf("spooled %v, want [3 4]", spooled)
+	}
+}
+
+type sinkFunc func([]Point) error
+
+func (f sinkFunc) Name() string               { return "func" }
+func (f sinkFunc) Write(points []Point) error { return f(points) }
+
+func TestSpoolKeepsPartOfBatch(t *testing.T) {
+	buf := newDiskBuffer(t.TempDir(), "fake", 0)
+	buf.append([]Point{point(1), point(2), point(3)})
+	buf.append([]Point{point(4)})
+
+	if err := buf.replay(func(points []Point) (int, error) { return 1, errors.New("timeout") }); err == nil {
+		t.Fatal("no error")
+	}
+	var rest [][]int64
+	buf.replay(func(points []Point) (int, error) {
+		var ts []int64
+		for _, p := range points {
+			ts = append(ts, p.Timestamp)
+		}
+		rest = append(rest, ts)
+		return len(points), nil
+	})
+	if len(rest) != 2 || len(rest[0]) != 2 || rest[0][0] != 2 || rest[1][0] != 4 {
+		t.Errorf("remaining = %v, want [[2 3] [4]]", rest)
+	}
+}
@@ -0,0 +1,228 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// ── InfluxDB line protocol over HTTP ─────────────────────────────
+
+type Influx struct {
+	url   string
+	token string
+}
+
+// NewInflux accepts either a v1 (/write?db=) or v2 (/api/v2/write?bucket=)
+// endpoint. Timestamps are sent in seconds, so precision=s is added if the
+// URL does not specify one.
+func NewInflux(rawURL, token string) *Influx {
+	if u, err := url.Parse(rawURL); err == nil {
+		q := u.Query()
+		if q.Get("precision") == "" {
+			q.Set("precision", "s")
+			u.RawQuery = q.Encode()
+			rawURL = u.String()
+		}
+	}
+	return &Influx{url: rawURL, token: token}
+}
+
+func (s *Influx) Name() string { return "influx" }
+
+func (s *Influx) Write(points []Point) error {
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(EncodeLineProtocol(points)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	return doRequest(req)
+}
+
+var (
+	lpMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	lpTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// EncodeLineProtocol renders points as InfluxDB line protocol, one line per
+// point with tags and fields in sorted order.
+func EncodeLineProtocol(points []Point) []byte {
+	var b bytes.Buffer
+	for _, p := range points {
+		b.WriteString(lpMeasurementEscaper.Replace(p.Measurement))
+		for _, k := range sortedKeys(p.Tags) {
+			v := p.Tags[k]
+			if v == "" {
+				continue
+			}
+			b.WriteByte(',')
+			b.WriteString(lpTagEscaper.Replace(k))
+			b.WriteByte('=')
+			b.WriteString(lpTagEscaper.Replace(v))
+		}
+		b.WriteByte(' ')
+		for i, k := range sortedKeys(p.Fields) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(lpTagEscaper.Replace(k))
+			b.WriteByte('=')
+			b.WriteString(strconv.FormatFloat(p.Fields[k], 'f', -1, 64))
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(p.Timestamp, 10))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// ── Graphite plaintext over TCP ──────────────────────────────────
+
+type Graphite struct {
+	addr   string
+	prefix string
+}
+
+func NewGraphite(addr, prefix string) *Graphite {
+	return &Graphite{addr: addr, prefix: strings.Trim(prefix, ".")}
+}
+
+func (s *Graphite) Name() string { return "graphite" }
+
+func (s *Graphite) Write(points []Point) error {
+	conn, err := net.DialTimeout("tcp", s.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(EncodeGraphite(s.prefix, points))
+	return err
+}
+
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+// EncodeGraphite renders points as "<prefix>.<host>.<measurement>[.<tag>].<field> <value> <ts>".
+// Tag values other than host are appended in sorted key order so series
+// names stay stable.
+func EncodeGraphite(prefix string, points []Point) []byte {
+	var b bytes.Buffer
+	for _, p := range points {
+		var path []string
+		if prefix != "" {
+			path = append(path, prefix)
+		}
+		if h := p.Tags["host"]; h != "" {
+			path = append(path, graphiteEscaper.Replace(h))
+		}
+		path = append(path, graphiteEscaper.Replace(p.Measurement))
+		for _, k := range sortedKeys(p.Tags) {
+			if k == "host" || k == "device" {
+				continue
+			}
+			v := strings.Trim(graphiteEscaper.Replace(p.Tags[k]), "_")
+			if v == "" {
+				v = "root"
+			}
+			path = append(path, v)
+		}
+		base := strings.Join(path, ".")
+		for _, k := range sortedKeys(p.Fields) {
+			fmt.Fprintf(&b, "%s.%s %s %d\n", base, k,
+				strconv.FormatFloat(p.Fields[k], 'f', -1, 64), p.Timestamp)
+		}
+	}
+	return b.Bytes()
+}
+
+// ── Generic JSON POST ────────────────────────────────────────────
+
+type JSON struct {
+	url     string
+	headers map[string]string
+}
+
+func NewJSON(url string, headers map[string]string) *JSON {
+	return &JSON{url: url, headers: headers}
+}
+
+func (s *JSON) Name() string { return "json" }
+
+func (s *JSON) Write(points []Point) error {
+	body, err := json.Marshal(map[string]interface{}{"points": points})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	return doRequest(req)
+}
+
+// RejectedError is a batch the backend refused as invalid. Sending it
+// again cannot succeed, so it is dropped rather than spooled. A batch
+// refused as TooLarge is split first; see writeSplitting.
+type RejectedError struct {
+	Err      error
+	TooLarge bool
+}
+
+func (e *RejectedError) Error() string { return "rejected: " + e.Err.Error() }
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// IsRejected reports whether err means the batch should be dropped.
+func IsRejected(err error) bool {
+	var r *RejectedError
+	return errors.As(err, &r)
+}
+
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("%s: %s %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+		// only a malformed payload is final; auth errors, rate limits and
+		// 5xx are retried so a misconfiguration fixed later loses nothing
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			return &RejectedError{Err: err}
+		case http.StatusRequestEntityTooLarge:
+			return &RejectedError{Err: err, TooLarge: true}
+		}
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,332 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// anomalyMetrics are the history columns a baseline can be built for.
+// version is the schema migration that added the column: rows written
+// before it read as 0 and are left out of the baseline.
+var anomalyMetrics = map[string]struct {
+	label   string
+	value   func(MetricPoint) float64
+	version int
+}{
+	"cpu":    {"CPU", func(p MetricPoint) float64 { return p.CPU }, 0},
+	"memory": {"内存", func(p MetricPoint) float64 { return p.Memory }, 0},
+	"disk":   {"磁盘", func(p MetricPoint) float64 { return p.Disk }, 0},
+
+	"cpu_pressure":    {"CPU 压力", func(p MetricPoint) float64 { return p.CPUPressure }, 7},
+	"memory_pressure": {"内存压力", func(p MetricPoint) float64 { return p.MemPressure }, 7},
+	"io_pressure":     {"IO 压力", func(p MetricPoint) float64 { return p.IOPressure }, 7},
+
+	"cpu_iowait": {"CPU iowait", func(p MetricPoint) float64 { return p.CPUIowait }, 10},
+	"cpu_steal":  {"CPU steal", func(p MetricPoint) float64 { return p.CPUSteal }, 10},
+}
+
+// migrationTimes is implemented by stores with a versioned schema.
+type migrationTimes interface {
+	MigratedAt(version int) (int64, error)
+}
+
+// minStdDev keeps near-constant metrics (idle CPU at 0.5% ± 0.05) from
+// turning every small blip into a huge score. Metrics are percentages, so
+// one percentage point is a reasonable floor.
+const minStdDev = 1.0
+
+// minOverallHours is how many hourly means the overall fallback needs.
+const minOverallHours = 24
+
+// BaselineBucket summarises one metric for one hour of the week. Raw
+// samples are first averaged per hour, so Samples counts hours: one per
+// week for an hour-of-week bucket, and the spread is between weeks rather
+// than the noise within one hour.
+type BaselineBucket struct {
+	Hour    int     `json:"hour"` // 0 = Sunday 00:00-01:00 local time, 167 = Saturday 23:00
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stddev"`
+}
+
+type baseline struct {
+	buckets [168]BaselineBucket
+	overall BaselineBucket // fallback for buckets with too few weeks
+}
+
+// AnomalyScore compares a value with its baseline. Score is the signed
+// number of standard deviations from the mean.
+type AnomalyScore struct {
+	Metric    string  `json:"metric"`
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"stddev"`
+	Score     float64 `json:"score"`
+	Samples   int     `json:"samples"`
+	Source    string  `json:"source"` // hour_of_week, overall or none
+	Anomalous bool    `json:"anomalous"`
+	Since     int64   `json:"since,omitempty"` // when the current deviation started
+}
+
+// Detector builds per-metric hour-of-week baselines from stored history and
+// scores new values against them.
+type Detector struct {
+	st  Store
+	cfg config.AnomalyConfig
+
+	mu        sync.RWMutex
+	baselines map[string]*baseline
+	builtAt   time.Time
+	since     map[string]time.Time
+}
+
+func NewDetector(st Store, cfg config.AnomalyConfig) *Detector {
+	return &Detector{st: st, cfg: cfg, baselines: map[string]*baseline{}, since: map[string]time.Time{}}
+}
+
+// Start rebuilds the baselines now and then hourly.
+func (d *Detector) Start() {
+	rebuild := func() {
+		if err := d.Rebuild(); err != nil {
+			log.Printf("anomaly baseline: %v", err)
+		}
+	}
+	// the first build reads the whole window, so it does not hold up
+	// startup; until it is done every score has source "none"
+	go func() {
+		rebuild()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			rebuild()
+		}
+	}()
+}
+
+// Rebuild recomputes every baseline from the configured history window.
+func (d *Detector) Rebuild() error {
+	window := d.cfg.Window
+	if window <= 0 {
+		window = 28 * 24 * time.Hour
+	}
+	type acc struct{ n, sum, sumSq float64 }
+	// first pass: mean of every metric per local clock hour
+	hourly := map[string]map[int64]*acc{}
+	for name := range anomalyMetrics {
+		hourly[name] = map[int64]*acc{}
+	}
+	// rows older than the migration that added a column hold 0, not data
+	since := map[string]int64{}
+	if mt, ok := d.st.(migrationTimes); ok {
+		for name, m := range anomalyMetrics {
+			if m.version == 0 {
+				continue
+			}
+			at, err := mt.MigratedAt(m.version)
+			if err != nil {
+				return err
+			}
+			since[name] = at
+		}
+	}
+	now := time.Now()
+	err := d.st.MetricsRange(now.Add(-window).Unix(), now.Unix(), func(p MetricPoint) error {
+		t := time.Unix(p.Timestamp, 0)
+		hour := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Unix()
+		for name, m := range anomalyMetrics {
+			if p.Timestamp < since[name] {
+				continue
+			}
+			a := hourly[name][hour]
+			if a == nil {
+				a = &acc{}
+				hourly[name][hour] = a
+			}
+			a.n++
+			a.sum += m.value(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	summarise := func(hour int, a acc) BaselineBucket {
+		b := BaselineBucket{Hour: hour, Samples: int(a.n)}
+		if a.n > 0 {
+			b.Mean = a.sum / a.n
+			b.StdDev = math.Sqrt(math.Max(a.sumSq/a.n-b.Mean*b.Mean, 0))
+		}
+		return b
+	}
+	baselines := map[string]*baseline{}
+	for name, hours := range hourly {
+		// second pass: spread of the hourly means per hour of the week
+		var buckets [169]acc // index 168 is the overall bucket
+		for hour, a := range hours {
+			mean := a.sum / a.n
+			for _, i := range []int{hourOfWeek(time.Unix(hour, 0)), 168} {
+				b := &buckets[i]
+				b.n++
+				b.sum += mean
+				b.sumSq += mean * mean
+			}
+		}
+		bl := &baseline{overall: summarise(-1, buckets[168])}
+		for h := 0; h < 168; h++ {
+			bl.buckets[h] = summarise(h, buckets[h])
+		}
+		baselines[name] = bl
+	}
+
+	d.mu.Lock()
+	d.baselines = baselines
+	d.builtAt = now
+	d.mu.Unlock()
+	return nil
+}
+
+// Baseline returns the 168 hour-of-week buckets for metric.
+func (d *Detector) Baseline(metric string) ([]BaselineBucket, time.Time, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	bl, ok := d.baselines[metric]
+	if !ok {
+		if _, known := anomalyMetrics[metric]; !known {
+			return nil, d.builtAt, fmt.Errorf("unknown metric %q", metric)
+		}
+		return []BaselineBucket{}, d.builtAt, nil
+	}
+	return append([]BaselineBucket(nil), bl.buckets[:]...), d.builtAt, nil
+}
+
+// Score scores every configured metric of p against its baseline.
+func (d *Detector) Score(p MetricPoint) []AnomalyScore {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var scores []AnomalyScore
+	for _, name := range d.cfg.Metrics {
+		if _, ok := anomalyMetrics[name]; !ok {
+			continue
+		}
+		s := d.score(name, p)
+		if t, ok := d.since[name]; ok {
+			s.Since = t.Unix()
+		}
+		scores = append(scores, s)
+	}
+	return scores
+}
+
+// Latest scores the most recent stored sample.
+func (d *Detector) Latest() ([]AnomalyScore, error) {
+	now := time.Now()
+	var last *MetricPoint
+	err := d.st.MetricsRange(now.Add(-10*time.Minute).Unix(), now.Unix(), func(p MetricPoint) error {
+		last = &p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return []AnomalyScore{}, nil
+	}
+	return d.Score(*last), nil
+}
+
+func (d *Detector) score(name string, p MetricPoint) AnomalyScore {
+	m := anomalyMetrics[name]
+	s := AnomalyScore{Metric: name, Timestamp: p.Timestamp, Value: m.value(p), Source: "none"}
+	bl, ok := d.baselines[name]
+	if !ok {
+		return s
+	}
+	minWeeks := d.cfg.MinWeeks
+	if minWeeks <= 0 {
+		minWeeks = 3
+	}
+	b := bl.buckets[hourOfWeek(time.Unix(p.Timestamp, 0))]
+	s.Source = "hour_of_week"
+	if b.Samples < minWeeks {
+		b = bl.overall
+		s.Source = "overall"
+	}
+	if s.Source == "overall" && b.Samples < minOverallHours {
+		s.Source = "none"
+		s.Samples = b.Samples
+		return s
+	}
+	s.Mean, s.StdDev, s.Samples = b.Mean, b.StdDev, b.Samples
+	s.Score = (s.Value - b.Mean) / math.Max(b.StdDev, minStdDev)
+	s.Anomalous = d.cfg.ZScore > 0 && math.Abs(s.Score) >= d.cfg.ZScore
+	return s
+}
+
+// StartAnomalyChecker scores a fresh sample every 30s and raises an alert
+// once a configured metric stays anomalous for cfg.Alert.Anomaly.Sustain.
+func StartAnomalyChecker(st Store, cfg *config.Config, det *Detector) {
+	sampler := &collector.CPUSampler{}
+	sampler.Sample()
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		det.checkSustained(st, cfg, metricPoint(collector.MetricsSnapshot{
+			Timestamp: time.Now().Unix(),
+			CPU:       sampler.Stats(),
+			Memory:    collector.GetMemoryStats(),
+			Disk:      collector.GetDiskStats(),
+			Pressure:  collector.GetPressure(),
+		}))
+	}
+}
+
+// checkSustained raises an alert for every configured metric whose score
+// has stayed beyond the threshold for at least cfg.Sustain.
+func (d *Detector) checkSustained(st Store, cfg *config.Config, p MetricPoint) {
+	if !d.cfg.Enabled {
+		return
+	}
+	now := time.Unix(p.Timestamp, 0)
+	for _, name := range d.cfg.Metrics {
+		if _, ok := anomalyMetrics[name]; !ok {
+			continue
+		}
+		d.mu.Lock()
+		s := d.score(name, p)
+		start, tracking := d.since[name]
+		if !s.Anomalous {
+			delete(d.since, name)
+			d.mu.Unlock()
+			continue
+		}
+		if !tracking {
+			start = now
+			d.since[name] = now
+		}
+		d.mu.Unlock()
+
+		if now.Sub(start) < d.cfg.Sustain {
+			continue
+		}
+		label := anomalyMetrics[name].label
+		RaiseAlert(st, cfg, Alert{
+			Type:      "异常(" + label + ")",
+			Value:     s.Value,
+			Threshold: d.cfg.ZScore,
+			Message: fmt.Sprintf("%s 当前 %.1f%%，偏离基线 %.1f±%.1f%%（z=%.1f），已持续 %s",
+				label, s.Value, s.Mean, s.StdDev, s.Score, now.Sub(start).Round(time.Second)),
+		})
+	}
+}
+
+func hourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// pushHour stores one hour of 5-second CPU samples starting at start.
+func pushHour(m *Memory, start time.Time, cpu float64) {
+	for ts := start.Unix(); ts < start.Add(time.Hour).Unix(); ts += 5 {
+		m.metrics.push(MetricPoint{Timestamp: ts, CPU: cpu})
+	}
+}
+
+func TestBaselineNeedsWeeks(t *testing.T) {
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).Add(-time.Hour)
+	cfg := config.AnomalyConfig{ZScore: 4, Window: 28 * 24 * time.Hour, MinWeeks: 3}
+
+	// a single week, however many raw samples, is not a baseline
+	m := NewMemory(100000)
+	pushHour(m, hour, 10)
+	d := NewDetector(m, cfg)
+	if err := d.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	s := d.score("cpu", MetricPoint{Timestamp: hour.Unix(), CPU: 50})
+	if s.Source != "none" || s.Anomalous {
+		t.Errorf("one week: source %s anomalous %v, want none", s.Source, s.Anomalous)
+	}
+
+	// the same hour over three weeks is
+	m = NewMemory(100000)
+	for w, cpu := range []float64{8, 10, 12} {
+		pushHour(m, hour.AddDate(0, 0, -7*w), cpu)
+	}
+	d = NewDetector(m, cfg)
+	if err := d.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	s = d.score("cpu", MetricPoint{Timestamp: hour.Unix(), CPU: 50})
+	if s.Source != "hour_of_week" || s.Samples != 3 {
+		t.Fatalf("three weeks: source %s samples %d, want hour_of_week with 3", s.Source, s.Samples)
+	}
+	// the spread is between the weekly means, not within an hour
+	if math.Abs(s.Mean-10) > 1e-9 || math.Abs(s.StdDev-math.Sqrt(8.0/3)) > 1e-9 {
+		t.Errorf("mean %g stddev %g, want 10 and %g", s.Mean, s.StdDev, math.Sqrt(8.0/3))
+	}
+	if !s.Anomalous {
+		t.Errorf("50%% against 10±1.6 not anomalous (score %g)", s.Score)
+	}
+	if s = d.score("cpu", MetricPoint{Timestamp: hour.Unix(), CPU: 11}); s.Anomalous {
+		t.Errorf("11%% against 10±1.6 anomalous (score %g)", s.Score)
+	}
+}
+
+func TestBaselineSkipsRowsBeforeMigration(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).Add(-time.Hour)
+	var batch []MetricPoint
+	for w := 0; w < 3; w++ {
+		start := hour.AddDate(0, 0, -7*w)
+		for ts := start.Unix(); ts < start.Add(time.Hour).Unix(); ts += 60 {
+			batch = append(batch, MetricPoint{Timestamp: ts, CPU: 10})
+		}
+	}
+	if err := s.insertMetrics(batch); err != nil {
+		t.Fatal(err)
+	}
+	// as if the pressure and CPU breakdown columns were added after the
+	// last of these rows
+	if _, err := s.db.Exec(`UPDATE schema_version SET applied_at=? WHERE version IN (7,10)`, now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDetector(s, config.AnomalyConfig{ZScore: 4, Window: 28 * 24 * time.Hour, MinWeeks: 3})
+	if err := d.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	p := MetricPoint{Timestamp: hour.Unix(), CPU: 10, CPUPressure: 30}
+	if sc := d.score("cpu", p); sc.Source != "hour_of_week" {
+		t.Errorf("cpu: source %s, want hour_of_week", sc.Source)
+	}
+	p.CPUIowait = 30
+	for _, name := range []string{"cpu_pressure", "cpu_iowait"} {
+		if sc := d.score(name, p); sc.Source != "none" || sc.Anomalous {
+			t.Errorf("%s: source %s anomalous %v, want none from zero-filled rows", name, sc.Source, sc.Anomalous)
+		}
+	}
+}
+
+func TestScoreConfiguredMetrics(t *testing.T) {
+	d := NewDetector(NewMemory(10), config.AnomalyConfig{Metrics: []string{"cpu", "io_pressure", "cpu_steal", "bogus"}})
+	var got []string
+	for _, s := range d.Score(MetricPoint{Timestamp: time.Now().Unix()}) {
+		got = append(got, s.Metric)
+	}
+	if len(got) != 3 || got[0] != "cpu" || got[1] != "io_pressure" || got[2] != "cpu_steal" {
+		t.Errorf("scored %q, want the configured known metrics", got)
+	}
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Backup writes a consistent snapshot of the live database to dest using
+// SQLite's online backup API. It reads through its own connection rather
+// than the store's single pooled one, and copies in one step: in WAL mode
+// that is a read transaction, so the panel keeps writing while it copies.
+func (s *SQLite) Backup(dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	destConn, err := openRawConn(dest)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := openRawConn(s.path)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	if err = copyDB(destConn, srcConn, -1); err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
+
+// Restore replaces the contents of the live database with the backup file
+// at src. The file must pass an integrity check and must not be from a newer
+// schema; after copying it is migrated to the current schema version.
+// The copy goes through the store's only connection, so collection and API
+// queries wait for it (at most copyDB's five minutes) instead of writing
+// into a database that is being replaced.
+func (s *SQLite) Restore(src string) error {
+	if err := verifyBackup(src); err != nil {
+		return err
+	}
+	srcConn, err := openRawConn(src)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	if err := withRawConn(s.db, func(dest *sqlite3.SQLiteConn) error {
+		return copyDB(dest, srcConn, 256)
+	}); err != nil {
+		return err
+	}
+	return migrate(s.db)
+}
+
+func verifyBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	bdb, err := openReadOnlyDB(path)
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer bdb.Close()
+	problems, err := integrityCheck(bdb)
+	if err != nil {
+		return fmt.Errorf("not a valid database: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup failed integrity check: %s", problems[0])
+	}
+	v, err := SchemaVersion(bdb)
+	if err != nil {
+		return err
+	}
+	if v > LatestSchemaVersion() {
+		return fmt.Errorf("backup schema version %d is newer than this build supports (%d)", v, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file, reclaiming space left by pruning.
+func (s *SQLite) Vacuum() error {
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the reported
+// problems; an empty slice means the database is healthy.
+func (s *SQLite) IntegrityCheck() ([]string, error) { return integrityCheck(s.db) }
+
+func integrityCheck(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// copyDB copies src into dest in chunks of pages (-1 for all at once),
+// backing off briefly while either side is locked by another connection.
+func copyDB(dest, src *sqlite3.SQLiteConn, pages int) error {
+	bk, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		before := bk.Remaining()
+		done, err := bk.Step(pages)
+		if err != nil {
+			bk.Finish()
+			return err
+		}
+		if done {
+			return bk.Finish()
+		}
+		if time.Now().After(deadline) {
+			bk.Finish()
+			return fmt.Errorf("backup timed out with %d pages remaining", bk.Remaining())
+		}
+		if bk.Remaining() == before {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
+
+func openRawConn(path string) (*sqlite3.SQLiteConn, error) {
+	c, err := (&sqlite3.SQLiteDriver{}).Open(path + "?_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	return c.(*sqlite3.SQLiteConn), nil
+}
+
+func withRawConn(db *sql.DB, fn func(*sqlite3.SQLiteConn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(dc interface{}) error {
+		sc, ok := dc.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", dc)
+		}
+		return fn(sc)
+	})
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// TCPStatePoint is the number of TCP sockets in each state at one time.
+type TCPStatePoint struct {
+	Timestamp   int64 `json:"timestamp"`
+	Established int   `json:"established"`
+	SynSent     int   `json:"syn_sent"`
+	SynRecv     int   `json:"syn_recv"`
+	FinWait1    int   `json:"fin_wait1"`
+	FinWait2    int   `json:"fin_wait2"`
+	TimeWait    int   `json:"time_wait"`
+	Close       int   `json:"close"`
+	CloseWait   int   `json:"close_wait"`
+	LastAck     int   `json:"last_ack"`
+	Listen      int   `json:"listen"`
+	Closing     int   `json:"closing"`
+}
+
+// tcpStateMemoryPoints bounds the memory backend: a week at one per minute.
+const tcpStateMemoryPoints = 10080
+
+func tcpStatePoint(ts int64, counts map[string]int) TCPStatePoint {
+	return TCPStatePoint{
+		Timestamp:   ts,
+		Established: counts["ESTABLISHED"],
+		SynSent:     counts["SYN_SENT"],
+		SynRecv:     counts["SYN_RECV"],
+		FinWait1:    counts["FIN_WAIT1"],
+		FinWait2:    counts["FIN_WAIT2"],
+		TimeWait:    counts["TIME_WAIT"],
+		Close:       counts["CLOSE"],
+		CloseWait:   counts["CLOSE_WAIT"],
+		LastAck:     counts["LAST_ACK"],
+		Listen:      counts["LISTEN"],
+		Closing:     counts["CLOSING"],
+	}
+}
+
+// StartConnectionRecorder records the TCP state counts every interval.
+func StartConnectionRecorder(st Store, cfg config.ConnectionHistoryConfig) {
+	interval := cfg.Interval
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		counts, err := collector.GetTCPStateCounts()
+		if err != nil {
+			log.Printf("tcp states: %v", err)
+			continue
+		}
+		if err := st.SaveTCPStates(tcpStatePoint(time.Now().Unix(), counts)); err != nil {
+			log.Printf("save tcp states: %v", err)
+		}
+	}
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveTCPStates(p TCPStatePoint) error {
+	_, err := s.db.Exec(`INSERT INTO tcp_states (timestamp,established,syn_sent,syn_recv,fin_wait1,fin_wait2,time_wait,close,close_wait,last_ack,listen,closing) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		p.Timestamp, p.Established, p.SynSent, p.SynRecv, p.FinWait1, p.FinWait2, p.TimeWait, p.Close, p.CloseWait, p.LastAck, p.Listen, p.Closing)
+	return err
+}
+
+func (s *SQLite) TCPStateHistory(from, to int64) ([]TCPStatePoint, error) {
+	rows, err := s.db.Query(`SELECT timestamp,established,syn_sent,syn_recv,fin_wait1,fin_wait2,time_wait,close,close_wait,last_ack,listen,closing FROM tcp_states WHERE timestamp>=? AND timestamp<=? ORDER BY timestamp ASC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []TCPStatePoint
+	for rows.Next() {
+		var p TCPStatePoint
+		if err := rows.Scan(&p.Timestamp, &p.Established, &p.SynSent, &p.SynRecv, &p.FinWait1, &p.FinWait2, &p.TimeWait, &p.Close, &p.CloseWait, &p.LastAck, &p.Listen, &p.Closing); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveTCPStates(p TCPStatePoint) error {
+	m.mu.Lock()
+	m.tcpStates.push(p)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) TCPStateHistory(from, to int64) ([]TCPStatePoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tcpStates.filter(func(p TCPStatePoint) bool { return p.Timestamp >= from && p.Timestamp <= to }), nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+// ContainerPoint is one docker stats sample. Network and block I/O are the
+// cumulative counters docker reports; the *Rate fields are bytes/s derived
+// from the previous sample and are filled in by ContainerRates.
+type ContainerPoint struct {
+	Timestamp      int64   `json:"timestamp"`
+	CPU            float64 `json:"cpu_percent"`
+	MemUsed        uint64  `json:"mem_used"`
+	MemLimit       uint64  `json:"mem_limit"`
+	MemPercent     float64 `json:"mem_percent"`
+	NetRx          uint64  `json:"net_rx"`
+	NetTx          uint64  `json:"net_tx"`
+	BlockRead      uint64  `json:"block_read"`
+	BlockWrite     uint64  `json:"block_write"`
+	NetRxRate      float64 `json:"net_rx_rate"`
+	NetTxRate      float64 `json:"net_tx_rate"`
+	BlockReadRate  float64 `json:"block_read_rate"`
+	BlockWriteRate float64 `json:"block_write_rate"`
+}
+
+// ContainerSeries describes a container that has recorded history.
+type ContainerSeries struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"container_id"` // most recent ID behind the name
+	Image       string `json:"image"`
+	FirstSeen   int64  `json:"first_seen"`
+	LastSeen    int64  `json:"last_seen"`
+}
+
+// containerMemoryPoints is the per-container ring size of the memory
+// backend: 24h at the 30s docker refresh interval.
+const containerMemoryPoints = 2880
+
+func recordable(c collector.Container) bool { return c.State == "running" && c.Name != "" }
+
+func containerPoint(ts int64, c collector.Container) ContainerPoint {
+	return ContainerPoint{
+		Timestamp: ts, CPU: c.CPU, MemUsed: c.MemUsed, MemLimit: c.MemLim, MemPercent: c.MemPct,
+		NetRx: c.NetRx, NetTx: c.NetTx, BlockRead: c.BlockRead, BlockWrite: c.BlockWrite,
+	}
+}
+
+// ContainerRates fills the *Rate fields from consecutive samples. A counter
+// that went backwards means the container was restarted or recreated, so
+// that interval gets no rate rather than a huge bogus one.
+func ContainerRates(points []ContainerPoint) {
+	rate := func(cur, prev uint64, secs float64) float64 {
+		if cur < prev {
+			return 0
+		}
+		return float64(cur-prev) / secs
+	}
+	for i := 1; i < len(points); i++ {
+		p, prev := &points[i], points[i-1]
+		secs := float64(p.Timestamp - prev.Timestamp)
+		if secs <= 0 {
+			continue
+		}
+		p.NetRxRate = rate(p.NetRx, prev.NetRx, secs)
+		p.NetTxRate = rate(p.NetTx, prev.NetTx, secs)
+		p.BlockReadRate = rate(p.BlockRead, prev.BlockRead, secs)
+		p.BlockWriteRate = rate(p.BlockWrite, prev.BlockWrite, secs)
+	}
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveContainerStats(ts int64, containers []collector.Container) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if !recordable(c) {
+			continue
+		}
+		p := containerPoint(ts, c)
+		if _, err := tx.Exec(`INSERT INTO container_metrics (timestamp,name,cpu_percent,mem_used,mem_limit,mem_percent,net_rx,net_tx,block_read,block_write) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+			ts, c.Name, p.CPU, p.MemUsed, p.MemLimit, p.MemPercent, p.NetRx, p.NetTx, p.BlockRead, p.BlockWrite); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO containers (name,container_id,image,first_seen,last_seen) VALUES (?,?,?,?,?)
+			ON CONFLICT(name) DO UPDATE SET container_id=excluded.container_id, image=excluded.image, last_seen=excluded.last_seen`,
+			c.Name, c.ID, c.Image, ts, ts); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ContainerHistory(name string, from, to int64) ([]ContainerPoint, error) {
+	rows, err := s.db.Query(`SELECT timestamp,cpu_percent,mem_used,mem_limit,mem_percent,net_rx,net_tx,block_read,block_write FROM container_metrics WHERE name=? AND timestamp>=? AND timestamp<=? ORDER BY timestamp ASC`, name, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ContainerPoint
+	for rows.Next() {
+		var p ContainerPoint
+		if err := rows.Scan(&p.Timestamp, &p.CPU, &p.MemUsed, &p.MemLimit, &p.MemPercent, &p.NetRx, &p.NetTx, &p.BlockRead, &p.BlockWrite); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) ContainerSeries() ([]ContainerSeries, error) {
+	rows, err := s.db.Query(`SELECT name,container_id,image,first_seen,last_seen FROM containers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ContainerSeries
+	for rows.Next() {
+		var c ContainerSeries
+		if err := rows.Scan(&c.Name, &c.ContainerID, &c.Image, &c.FirstSeen, &c.LastSeen); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveContainerStats(ts int64, containers []collector.Container) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range containers {
+		if !recordable(c) {
+			continue
+		}
+		r, ok := m.containers[c.Name]
+		if !ok {
+			r = newRing[ContainerPoint](containerMemoryPoints)
+			m.containers[c.Name] = r
+		}
+		r.push(containerPoint(ts, c))
+		series := m.containerSeries[c.Name]
+		if series.FirstSeen == 0 {
+			series = ContainerSeries{Name: c.Name, FirstSeen: ts}
+		}
+		series.ContainerID, series.Image, series.LastSeen = c.ID, c.Image, ts
+		m.containerSeries[c.Name] = series
+	}
+	return nil
+}
+
+func (m *Memory) ContainerHistory(name string, from, to int64) ([]ContainerPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.containers[name]
+	if !ok {
+		return nil, nil
+	}
+	return r.filter(func(p ContainerPoint) bool { return p.Timestamp >= from && p.Timestamp <= to }), nil
+}
+
+func (m *Memory) ContainerSeries() ([]ContainerSeries, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []ContainerSeries
+	for _, c := range m.containerSeries {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *Memory) pruneContainers(before int64) {
+	for name, r := range m.containers {
+		r.dropWhile(func(p ContainerPoint) bool { return p.Timestamp < before })
+		if m.containerSeries[name].LastSeen < before {
+			delete(m.containers, name)
+			delete(m.containerSeries, name)
+		}
+	}
+}
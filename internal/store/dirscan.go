@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/websocket"
+)
+
+const (
+	// dirScanMaxEntries and dirScanTimeout bound a single scan; whatever
+	// was counted by then is kept as a truncated result.
+	dirScanMaxEntries = 5000000
+	dirScanTimeout    = 30 * time.Minute
+	maxDirScanDepth   = 10
+	maxDirScanTop     = 200
+
+	// Finished scans keep their whole tree in memory, so only the
+	// maxDirScans newest are kept, each for at most dirScanTTL.
+	maxDirScans = 20
+	dirScanTTL  = 24 * time.Hour
+)
+
+// ErrScanRunning is returned when a scan is requested while another is in
+// progress; scanning two trees at once only makes both slower.
+var ErrScanRunning = errors.New("a scan is already running")
+
+// DirScan is the state of the latest scan of one directory.
+type DirScan struct {
+	Path       string                 `json:"path"`
+	State      string                 `json:"state"` // running, done, truncated, canceled or failed
+	Depth      int                    `json:"depth"`
+	Top        int                    `json:"top"`
+	StartedAt  int64                  `json:"started_at"`
+	FinishedAt int64                  `json:"finished_at,omitempty"`
+	Progress   collector.ScanProgress `json:"progress"`
+	Error      string                 `json:"error,omitempty"`
+	Result     *collector.DirEntry    `json:"result,omitempty"`
+}
+
+// DirScanner runs one du-style scan at a time in the background and keeps
+// the latest result per directory, up to maxDirScans of them for
+// dirScanTTL. Progress and completion are broadcast
+// on the hub as "dir_scan" events without the tree.
+type DirScanner struct {
+	hub *websocket.Hub
+
+	mu     sync.Mutex
+	scans  map[string]*DirScan
+	cancel context.CancelFunc // of the running scan, nil when idle
+	active string
+}
+
+func NewDirScanner(hub *websocket.Hub) *DirScanner {
+	return &DirScanner{hub: hub, scans: map[string]*DirScan{}}
+}
+
+// Start begins scanning path and returns immediately with its state.
+func (d *DirScanner) Start(path string, depth, top int) (DirScan, error) {
+	if !filepath.IsAbs(path) {
+		return DirScan{}, fmt.Errorf("path must be absolute")
+	}
+	path = filepath.Clean(path)
+	depth = min(max(depth, 1), maxDirScanDepth)
+	top = min(max(top, 1), maxDirScanTop)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel != nil {
+		return DirScan{}, ErrScanRunning
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dirScanTimeout)
+	scan := &DirScan{Path: path, State: "running", Depth: depth, Top: top, StartedAt: time.Now().Unix()}
+	d.scans[path] = scan
+	d.prune(time.Now())
+	d.cancel, d.active = cancel, path
+
+	go d.run(ctx, scan)
+	return scan.summary(), nil
+}
+
+func (d *DirScanner) run(ctx context.Context, scan *DirScan) {
+	opts := collector.ScanOptions{Depth: scan.Depth, TopN: scan.Top, MaxEntries: dirScanMaxEntries}
+	result, progress, err := collector.ScanDir(ctx, scan.Path, opts, func(p collector.ScanProgress) {
+		d.mu.Lock()
+		scan.Progress = p
+		s := scan.summary()
+		d.mu.Unlock()
+		d.hub.Broadcast("dir_scan", s)
+	})
+
+	d.mu.Lock()
+	d.cancel()
+	d.cancel, d.active = nil, ""
+	scan.Result, scan.Progress, scan.FinishedAt = result, progress, time.Now().Unix()
+	switch {
+	case err == nil:
+		scan.State = "done"
+	case errors.Is(err, collector.ErrScanLimit), errors.Is(err, context.DeadlineExceeded):
+		scan.State, scan.Error = "truncated", err.Error()
+	case errors.Is(err, context.Canceled):
+		scan.State = "canceled"
+	default:
+		scan.State, scan.Error = "failed", err.Error()
+	}
+	s := scan.summary()
+	d.mu.Unlock()
+	d.hub.Broadcast("dir_scan", s)
+}
+
+// Get returns the latest scan of path, with its tree once finished.
+func (d *DirScanner) Get(path string) (DirScan, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(time.Now())
+	scan, ok := d.scans[filepath.Clean(path)]
+	if !ok {
+		return DirScan{}, false
+	}
+	s := scan.summary()
+	if scan.State != "running" {
+		s.Result = scan.Result
+	}
+	return s, true
+}
+
+// Cancel stops the running scan of path. A canceled scan keeps the
+// partial tree counted so far.
+func (d *DirScanner) Cancel(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel == nil || d.active != filepath.Clean(path) {
+		return false
+	}
+	d.cancel()
+	return true
+}
+
+// List returns every known scan without its tree, newest first.
+func (d *DirScanner) List() []DirScan {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(time.Now())
+	out := make([]DirScan, 0, len(d.scans))
+	for _, scan := range d.scans {
+		out = append(out, scan.summary())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt > out[j].StartedAt })
+	return out
+}
+
+// prune drops finished scans older than dirScanTTL and then the oldest
+// finished ones beyond maxDirScans. The running scan is never dropped.
+// Called with d.mu held.
+func (d *DirScanner) prune(now time.Time) {
+	var finished []*DirScan
+	for path, scan := range d.scans {
+		if scan.State == "running" {
+			continue
+		}
+		if now.Sub(time.Unix(scan.FinishedAt, 0)) > dirScanTTL {
+			delete(d.scans, path)
+			continue
+		}
+		finished = append(finished, scan)
+	}
+	if extra := len(d.scans) - maxDirScans; extra > 0 {
+		sort.Slice(finished, func(i, j int) bool { return finished[i].StartedAt < finished[j].StartedAt })
+		for _, scan := range finished[:min(extra, len(finished))] {
+			delete(d.scans, scan.Path)
+		}
+	}
+}
+
+// summary copies the scan without its result tree.
+func (s *DirScan) summary() DirScan {
+	c := *s
+	c.Result = nil
+	return c
+}
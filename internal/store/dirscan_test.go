@@ -0,0 +1,33 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDirScannerPrune(t *testing.T) {
+	now := time.Now()
+	d := NewDirScanner(nil)
+	d.scans["/running"] = &DirScan{Path: "/running", State: "running", StartedAt: now.Add(-48 * time.Hour).Unix()}
+	d.scans["/stale"] = &DirScan{Path: "/stale", State: "done", StartedAt: now.Add(-26 * time.Hour).Unix(), FinishedAt: now.Add(-25 * time.Hour).Unix()}
+	for i := 0; i < maxDirScans+2; i++ {
+		path := fmt.Sprintf("/d%02d", i)
+		d.scans[path] = &DirScan{Path: path, State: "done", StartedAt: now.Add(time.Duration(i-30) * time.Minute).Unix(), FinishedAt: now.Unix()}
+	}
+
+	d.prune(now)
+	if len(d.scans) != maxDirScans {
+		t.Fatalf("%d scans kept, want %d", len(d.scans), maxDirScans)
+	}
+	for _, path := range []string{"/stale", "/d00", "/d01", "/d02"} {
+		if _, ok := d.scans[path]; ok {
+			t.Errorf("%s kept", path)
+		}
+	}
+	for _, path := range []string{"/running", "/d03", fmt.Sprintf("/d%02d", maxDirScans+1)} {
+		if _, ok := d.scans[path]; !ok {
+			t.Errorf("%s dropped", path)
+		}
+	}
+}
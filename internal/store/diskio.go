@@ -0,0 +1,135 @@
+package store
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// DiskIOPoint is the average activity of one block device over one
+// recording interval.
+type DiskIOPoint struct {
+	Timestamp  int64   `json:"timestamp"`
+	Device     string  `json:"device"`
+	ReadBps    float64 `json:"read_bps"`
+	WriteBps   float64 `json:"write_bps"`
+	ReadIOPS   float64 `json:"read_iops"`
+	WriteIOPS  float64 `json:"write_iops"`
+	ReadAwait  float64 `json:"read_await_ms"`
+	WriteAwait float64 `json:"write_await_ms"`
+	Util       float64 `json:"util"`
+}
+
+// diskIOMemoryPoints bounds the memory backend per device: two days at
+// one per minute.
+const diskIOMemoryPoints = 2880
+
+func diskIOPoint(ts int64, b collector.BlockIO) DiskIOPoint {
+	return DiskIOPoint{
+		Timestamp: ts, Device: b.Name,
+		ReadBps: b.ReadBps, WriteBps: b.WriteBps, ReadIOPS: b.ReadIOPS, WriteIOPS: b.WriteIOPS,
+		ReadAwait: b.ReadAwait, WriteAwait: b.WriteAwait, Util: b.Util,
+	}
+}
+
+// StartDiskIORecorder records per-device I/O every interval. It keeps its
+// own sampler so each point averages the whole interval rather than the
+// few seconds since the last live refresh.
+func StartDiskIORecorder(st Store, cfg config.DiskIOHistoryConfig) {
+	interval := cfg.Interval
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	sampler := &collector.DiskIOSampler{}
+	sampler.Sample()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := st.SaveDiskIO(time.Now().Unix(), sampler.Sample()); err != nil {
+			log.Printf("save disk io: %v", err)
+		}
+	}
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveDiskIO(ts int64, devices []collector.BlockIO) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, b := range devices {
+		p := diskIOPoint(ts, b)
+		if _, err := tx.Exec(`INSERT INTO disk_io (timestamp,device,read_bps,write_bps,read_iops,write_iops,read_await,write_await,util) VALUES (?,?,?,?,?,?,?,?,?)`,
+			ts, p.Device, p.ReadBps, p.WriteBps, p.ReadIOPS, p.WriteIOPS, p.ReadAwait, p.WriteAwait, p.Util); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) DiskIOHistory(device string, from, to int64) ([]DiskIOPoint, error) {
+	rows, err := s.db.Query(`SELECT timestamp,device,read_bps,write_bps,read_iops,write_iops,read_await,write_await,util FROM disk_io
+		WHERE (?='' OR device=?) AND timestamp>=? AND timestamp<=? ORDER BY timestamp ASC, device ASC`, device, device, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []DiskIOPoint
+	for rows.Next() {
+		var p DiskIOPoint
+		if err := rows.Scan(&p.Timestamp, &p.Device, &p.ReadBps, &p.WriteBps, &p.ReadIOPS, &p.WriteIOPS, &p.ReadAwait, &p.WriteAwait, &p.Util); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveDiskIO(ts int64, devices []collector.BlockIO) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range devices {
+		r, ok := m.diskIO[b.Name]
+		if !ok {
+			r = newRing[DiskIOPoint](diskIOMemoryPoints)
+			m.diskIO[b.Name] = r
+		}
+		r.push(diskIOPoint(ts, b))
+	}
+	return nil
+}
+
+func (m *Memory) DiskIOHistory(device string, from, to int64) ([]DiskIOPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []DiskIOPoint
+	for name, r := range m.diskIO {
+		if device != "" && name != device {
+			continue
+		}
+		result = append(result, r.filter(func(p DiskIOPoint) bool { return p.Timestamp >= from && p.Timestamp <= to })...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp != result[j].Timestamp {
+			return result[i].Timestamp < result[j].Timestamp
+		}
+		return result[i].Device < result[j].Device
+	})
+	return result, nil
+}
+
+func (m *Memory) pruneDiskIO(before int64) {
+	for name, r := range m.diskIO {
+		r.dropWhile(func(p DiskIOPoint) bool { return p.Timestamp < before })
+		if r.n == 0 {
+			delete(m.diskIO, name)
+		}
+	}
+}
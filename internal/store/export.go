@@ -0,0 +1,99 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+var exportColumns = []string{"timestamp", "time", "cpu_percent", "mem_percent", "disk_percent", "net_recv", "net_sent",
+	"cpu_pressure", "mem_pressure", "io_pressure", "mem_pressure_full", "io_pressure_full",
+	"cpu_user", "cpu_system", "cpu_nice", "cpu_iowait", "cpu_irq", "cpu_softirq", "cpu_steal", "cpu_guest",
+	"cpu_pressure_avg60", "mem_pressure_avg60", "io_pressure_avg60"}
+
+// ExportMetrics streams metric rows with from <= timestamp <= to to w as
+// "csv" (with header) or "ndjson". Rows are written as they are read so
+// large ranges never sit in memory.
+func ExportMetrics(st Store, w io.Writer, from, to int64, format string) error {
+	if format != "csv" && format != "ndjson" {
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	bw := bufio.NewWriter(w)
+	var cw *csv.Writer
+	enc := json.NewEncoder(bw)
+	if format == "csv" {
+		cw = csv.NewWriter(bw)
+		cw.Write(exportColumns)
+	}
+	err := st.MetricsRange(from, to, func(p MetricPoint) error {
+		iso := time.Unix(p.Timestamp, 0).UTC().Format(time.RFC3339)
+		if cw != nil {
+			return cw.Write([]string{
+				strconv.FormatInt(p.Timestamp, 10), iso,
+				strconv.FormatFloat(p.CPU, 'f', 2, 64),
+				strconv.FormatFloat(p.Memory, 'f', 2, 64),
+				strconv.FormatFloat(p.Disk, 'f', 2, 64),
+				strconv.FormatUint(p.NetRecv, 10),
+				strconv.FormatUint(p.NetSent, 10),
+				strconv.FormatFloat(p.CPUPressure, 'f', 2, 64),
+				strconv.FormatFloat(p.MemPressure, 'f', 2, 64),
+				strconv.FormatFloat(p.IOPressure, 'f', 2, 64),
+				strconv.FormatFloat(p.MemPressureFull, 'f', 2, 64),
+				strconv.FormatFloat(p.IOPressureFull, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUUser, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUSystem, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUNice, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUIowait, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUIrq, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUSoftirq, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUSteal, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUGuest, 'f', 2, 64),
+				strconv.FormatFloat(p.CPUPressure60, 'f', 2, 64),
+				strconv.FormatFloat(p.MemPressure60, 'f', 2, 64),
+				strconv.FormatFloat(p.IOPressure60, 'f', 2, 64),
+			})
+		}
+		return enc.Encode(map[string]interface{}{
+			"timestamp": p.Timestamp, "time": iso, "cpu_percent": p.CPU, "mem_percent": p.Memory,
+			"disk_percent": p.Disk, "net_recv": p.NetRecv, "net_sent": p.NetSent,
+			"cpu_pressure": p.CPUPressure, "mem_pressure": p.MemPressure, "io_pressure": p.IOPressure,
+			"mem_pressure_full": p.MemPressureFull, "io_pressure_full": p.IOPressureFull,
+			"cpu_user": p.CPUUser, "cpu_system": p.CPUSystem, "cpu_nice": p.CPUNice, "cpu_iowait": p.CPUIowait,
+			"cpu_irq": p.CPUIrq, "cpu_softirq": p.CPUSoftirq, "cpu_steal": p.CPUSteal, "cpu_guest": p.CPUGuest,
+			"cpu_pressure_avg60": p.CPUPressure60, "mem_pressure_avg60": p.MemPressure60, "io_pressure_avg60": p.IOPressure60,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ParseTimeArg accepts a unix timestamp, an RFC3339 time or a plain date
+// (2006-01-02, local time). An empty string returns def.
+func ParseTimeArg(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/websocket"
+)
+
+// KernelEvent is a stored kernel log event.
+type KernelEvent struct {
+	ID int64 `json:"id"`
+	collector.KernelEvent
+}
+
+const (
+	kernelEventMemorySize = 1000
+	maxKernelMessageLen   = 1024
+)
+
+var kernelEventLabels = map[string]string{
+	collector.KernelOOMKill:    "OOM",
+	collector.KernelSegfault:   "段错误",
+	collector.KernelIOError:    "I/O 错误",
+	collector.KernelFSReadOnly: "文件系统只读",
+}
+
+// StartKernelEvents follows the kernel log, stores every matching event,
+// pushes it to WebSocket clients as "kernel_event" and raises an alert
+// for the types listed in cfg.KernelEvents.AlertTypes. Events still in the
+// kernel's buffer from before a restart are picked up once; see kernelSeen.
+func StartKernelEvents(st Store, cfg *config.Config, hub *websocket.Hub) {
+	alertTypes := map[string]bool{}
+	for _, t := range cfg.KernelEvents.AlertTypes {
+		alertTypes[t] = true
+	}
+	seen := newKernelSeen(st)
+	handle := func(e collector.KernelEvent) {
+		if len(e.Message) > maxKernelMessageLen {
+			e.Message = e.Message[:maxKernelMessageLen]
+		}
+		if !seen.fresh(e) {
+			return
+		}
+		if err := st.SaveKernelEvent(e); err != nil {
+			log.Printf("save kernel event: %v", err)
+		}
+		hub.Broadcast("kernel_event", e)
+		if !alertTypes[e.Type] {
+			return
+		}
+		subject := e.Process
+		if subject == "" {
+			subject = e.Device
+		}
+		label := kernelEventLabels[e.Type]
+		RaiseAlert(st, cfg, Alert{
+			Type:      label + "(" + subject + ")",
+			Timestamp: e.Timestamp,
+			Value:     float64(e.PID),
+			Message:   fmt.Sprintf("内核事件 %s：%s", label, e.Message),
+		})
+	}
+	for {
+		seen.replay()
+		err := collector.FollowKernelLog(seen.since, handle)
+		log.Printf("kernel log: %v, retrying in 1m", err)
+		time.Sleep(time.Minute)
+	}
+}
+
+// kernelSeen recognises events that were already recorded when the kernel
+// log is read again from the second of the latest recorded event. Events
+// only have one-second timestamps, so those in that second are matched on
+// type and message, counting repeats.
+type kernelSeen struct {
+	since int64          // second of the latest recorded event
+	seen  map[string]int // events recorded in that second
+	skip  map[string]int // of those, the ones not yet met again in this replay
+}
+
+func newKernelSeen(st Store) *kernelSeen {
+	k := &kernelSeen{seen: map[string]int{}}
+	latest, err := st.KernelEvents(0, time.Now().Unix()+1, "", 1)
+	if err != nil || len(latest) == 0 {
+		return k
+	}
+	k.since = latest[0].Timestamp
+	same, _ := st.KernelEvents(k.since, k.since, "", kernelEventMemorySize)
+	for _, e := range same {
+		k.seen[kernelEventKey(e.KernelEvent)]++
+	}
+	return k
+}
+
+// replay starts another pass over the kernel log from k.since.
+func (k *kernelSeen) replay() {
+	k.skip = map[string]int{}
+	for key, n := range k.seen {
+		k.skip[key] = n
+	}
+}
+
+// fresh reports whether e still has to be recorded and notes it if so.
+func (k *kernelSeen) fresh(e collector.KernelEvent) bool {
+	key := kernelEventKey(e)
+	switch {
+	case e.Timestamp < k.since:
+		return false
+	case e.Timestamp == k.since && k.skip[key] > 0:
+		k.skip[key]--
+		return false
+	case e.Timestamp > k.since:
+		k.since, k.seen, k.skip = e.Timestamp, map[string]int{}, map[string]int{}
+	}
+	k.seen[key]++
+	return true
+}
+
+func kernelEventKey(e collector.KernelEvent) string { return e.Type + "\x00" + e.Message }
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveKernelEvent(e collector.KernelEvent) error {
+	_, err := s.db.Exec(`INSERT INTO kernel_events (timestamp,type,pid,process,device,message) VALUES (?,?,?,?,?,?)`,
+		e.Timestamp, e.Type, e.PID, e.Process, e.Device, e.Message)
+	return err
+}
+
+func (s *SQLite) KernelEvents(from, to int64, typ string, limit int) ([]KernelEvent, error) {
+	rows, err := s.db.Query(`SELECT id,timestamp,type,pid,process,device,message FROM kernel_events
+		WHERE timestamp>=? AND timestamp<=? AND (?='' OR type=?) ORDER BY timestamp DESC, id DESC LIMIT ?`,
+		from, to, typ, typ, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []KernelEvent
+	for rows.Next() {
+		var e KernelEvent
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Type, &e.PID, &e.Process, &e.Device, &e.Message); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveKernelEvent(e collector.KernelEvent) error {
+	m.mu.Lock()
+	m.kernelEventID++
+	m.kernelEvents.push(KernelEvent{ID: m.kernelEventID, KernelEvent: e})
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) KernelEvents(from, to int64, typ string, limit int) ([]KernelEvent, error) {
+	m.mu.RLock()
+	all := m.kernelEvents.filter(func(e KernelEvent) bool {
+		return e.Timestamp >= from && e.Timestamp <= to && (typ == "" || e.Type == typ)
+	})
+	m.mu.RUnlock()
+	var result []KernelEvent
+	for i := len(all) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, all[i])
+	}
+	return result, nil
+}
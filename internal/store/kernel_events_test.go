@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+func TestKernelSeenSameSecond(t *testing.T) {
+	oom := collector.KernelEvent{Timestamp: 100, Type: collector.KernelOOMKill, Message: "Out of memory: Killed process 1 (a)"}
+	io := collector.KernelEvent{Timestamp: 100, Type: collector.KernelIOError, Message: "I/O error, dev sda"}
+	io2 := io
+	io2.Message = "I/O error, dev sdb"
+
+	m := NewMemory(10)
+	m.SaveKernelEvent(collector.KernelEvent{Timestamp: 99, Type: collector.KernelSegfault, Message: "x[1]: segfault at 0"})
+	m.SaveKernelEvent(oom)
+	m.SaveKernelEvent(io)
+	m.SaveKernelEvent(io)
+
+	// after a restart the kernel log is replayed from second 100
+	k := newKernelSeen(m)
+	k.replay()
+	for i, tc := range []struct {
+		e     collector.KernelEvent
+		fresh bool
+	}{
+		{collector.KernelEvent{Timestamp: 99, Type: collector.KernelSegfault, Message: "x[1]: segfault at 0"}, false},
+		{oom, false},
+		{io, false},
+		{io, false},
+		{io, true},  // a third identical message in that second is new
+		{io2, true}, // as is a different one the last run never saw
+		{collector.KernelEvent{Timestamp: 101, Type: collector.KernelIOError, Message: "I/O error, dev sda"}, true},
+	} {
+		if got := k.fresh(tc.e); got != tc.fresh {
+			t.Errorf("event %d: fresh = %v, want %v", i, got, tc.fresh)
+		}
+	}
+
+	// a retry after the log reader failed skips what this run recorded
+	k.replay()
+	if k.fresh(collector.KernelEvent{Timestamp: 101, Type: collector.KernelIOError, Message: "I/O error, dev sda"}) {
+		t.Error("event recorded before the retry seen as fresh")
+	}
+}
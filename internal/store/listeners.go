@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// listenerAllowed matches l against entries like "22", "tcp/443" or a
+// process name.
+func listenerAllowed(l collector.Listener, allowed []string) bool {
+	proto := strings.TrimSuffix(l.Proto, "6")
+	port := strconv.Itoa(int(l.Port))
+	for _, a := range allowed {
+		if a == port || a == proto+"/"+port || (l.Process != "" && a == l.Process) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerForget is how long a listener may be gone before it counts as
+// new again; long enough that restarting a service does not re-alert.
+const listenerForget = time.Hour
+
+// StartListenerWatch scans the listening sockets every minute and alerts
+// once for each new public listener that is not expected. UDP client
+// sockets on ephemeral ports are not listeners and are ignored.
+func StartListenerWatch(st Store, cfg *config.Config) {
+	lc := cfg.Alert.Listeners
+	known := map[string]time.Time{} // key → last seen
+	scan := func(seed bool) {
+		listeners, err := collector.GetListeners()
+		if err != nil {
+			log.Printf("listener scan: %v", err)
+			return
+		}
+		now := time.Now()
+		for key, seen := range known {
+			if now.Sub(seen) > listenerForget {
+				delete(known, key)
+			}
+		}
+		for _, l := range listeners {
+			if !l.Public || l.Ephemeral {
+				continue
+			}
+			_, old := known[l.Key()]
+			known[l.Key()] = now
+			if old || seed || listenerAllowed(l, lc.Allowed) {
+				continue
+			}
+			owner := l.Process
+			if owner == "" {
+				owner = "未知进程"
+			}
+			if l.Unit != "" {
+				owner += "，" + l.Unit
+			}
+			RaiseAlert(st, cfg, Alert{
+				Type:    "新监听端口(" + l.Key() + ")",
+				Value:   float64(l.Port),
+				Message: fmt.Sprintf("发现新的公网监听 %s（PID %d，%s）", l.Key(), l.PID, owner),
+			})
+		}
+	}
+	// without an allow list, whatever listens at startup is the baseline
+	scan(len(lc.Allowed) == 0)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		scan(false)
+	}
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+// Memory keeps history in fixed-size ring buffers and never touches disk,
+// for diskless or SD-card based devices. Everything is lost on restart.
+type Memory struct {
+	mu      sync.RWMutex
+	metrics *ring[MetricPoint]
+	alerts  *ring[Alert]
+	alertID int64
+
+	containers      map[string]*ring[ContainerPoint]
+	containerSeries map[string]ContainerSeries
+	services        map[string]*ring[ServicePoint]
+	processes       *ring[ProcessSample]
+	tcpStates       *ring[TCPStatePoint]
+	kernelEvents    *ring[KernelEvent]
+	kernelEventID   int64
+	diskIO          map[string]*ring[DiskIOPoint]
+	trafficCounters map[string]TrafficCounter
+	trafficDays     map[[2]string]*TrafficUsage // day, iface
+	trafficAlerts   map[string]map[float64]bool // cycle start → percent
+}
+
+// NewMemory creates a memory backend holding up to points metric samples
+// (default 17280, i.e. 24h at a 5s interval).
+func NewMemory(points int) *Memory {
+	if points <= 0 {
+		points = 17280
+	}
+	return &Memory{
+		metrics: newRing[MetricPoint](points),
+		alerts:  newRing[Alert](500),
+
+		containers:      map[string]*ring[ContainerPoint]{},
+		containerSeries: map[string]ContainerSeries{},
+		services:        map[string]*ring[ServicePoint]{},
+		processes:       newRing[ProcessSample](processMemorySamples),
+		tcpStates:       newRing[TCPStatePoint](tcpStateMemoryPoints),
+		kernelEvents:    newRing[KernelEvent](kernelEventMemorySize),
+		diskIO:          map[string]*ring[DiskIOPoint]{},
+		trafficCounters: map[string]TrafficCounter{},
+		trafficDays:     map[[2]string]*TrafficUsage{},
+		trafficAlerts:   map[string]map[float64]bool{},
+	}
+}
+
+func (m *Memory) Close() error { return nil }
+
+func (m *Memory) Stats() WriteStats { return WriteStats{Backend: "memory"} }
+
+func (m *Memory) SaveMetrics(snap collector.MetricsSnapshot) error {
+	m.mu.Lock()
+	m.metrics.push(metricPoint(snap))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) MetricsRange(from, to int64, fn func(MetricPoint) error) error {
+	m.mu.RLock()
+	points := m.metrics.filter(func(p MetricPoint) bool { return p.Timestamp >= from && p.Timestamp <= to })
+	m.mu.RUnlock()
+	for _, p := range points {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) SaveAlert(a Alert) error {
+	m.mu.Lock()
+	m.alertID++
+	a.ID = m.alertID
+	m.alerts.push(a)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Alerts(limit int) ([]Alert, error) {
+	m.mu.RLock()
+	all := m.alerts.filter(nil)
+	m.mu.RUnlock()
+	var result []Alert
+	for i := len(all) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		result = append(result, all[i])
+	}
+	return result, nil
+}
+
+func (m *Memory) Prune(before int64) error {
+	m.mu.Lock()
+	m.metrics.dropWhile(func(p MetricPoint) bool { return p.Timestamp < before })
+	m.pruneContainers(before)
+	m.pruneServices(before)
+	m.processes.dropWhile(func(p ProcessSample) bool { return p.Timestamp < before })
+	m.tcpStates.dropWhile(func(p TCPStatePoint) bool { return p.Timestamp < before })
+	m.kernelEvents.dropWhile(func(e KernelEvent) bool { return e.Timestamp < before })
+	m.pruneDiskIO(before)
+	m.mu.Unlock()
+	return nil
+}
+
+// ring is a fixed-capacity FIFO that overwrites its oldest entry when full.
+// Callers provide locking.
+type ring[T any] struct {
+	buf   []T
+	start int
+	n     int
+}
+
+func newRing[T any](capacity int) *ring[T] {
+	return &ring[T]{buf: make([]T, capacity)}
+}
+
+func (r *ring[T]) push(v T) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+func (r *ring[T]) at(i int) T { return r.buf[(r.start+i)%len(r.buf)] }
+
+// filter returns the entries oldest first, keeping those keep accepts
+// (all of them if keep is nil).
+func (r *ring[T]) filter(keep func(T) bool) []T {
+	var out []T
+	for i := 0; i < r.n; i++ {
+		if v := r.at(i); keep == nil || keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// dropWhile removes entries from the oldest end while drop returns true.
+func (r *ring[T]) dropWhile(drop func(T) bool) {
+	var zero T
+	for r.n > 0 && drop(r.at(0)) {
+		r.buf[r.start] = zero
+		r.start = (r.start + 1) % len(r.buf)
+		r.n--
+	}
+}
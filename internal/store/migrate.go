@@ -0,0 +1,241 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration upgrades the schema by one version. Migrations are append-only:
+// never edit one that has shipped, add a new version instead.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+var migrations = []migration{
+	{1, "initial schema", `
+		CREATE TABLE IF NOT EXISTS metrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			cpu_percent REAL,
+			mem_percent REAL,
+			disk_percent REAL,
+			net_recv INTEGER,
+			net_sent INTEGER
+		);
+		CREATE INDEX IF NOT EXISTS idx_metrics_ts ON metrics(timestamp);
+		CREATE TABLE IF NOT EXISTS alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			value REAL,
+			threshold REAL,
+			message TEXT
+		);
+	`},
+	{2, "container history", `
+		CREATE TABLE container_metrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			cpu_percent REAL,
+			mem_used INTEGER,
+			mem_limit INTEGER,
+			mem_percent REAL,
+			net_rx INTEGER,
+			net_tx INTEGER,
+			block_read INTEGER,
+			block_write INTEGER
+		);
+		CREATE INDEX idx_container_metrics_name_ts ON container_metrics(name, timestamp);
+		CREATE INDEX idx_container_metrics_ts ON container_metrics(timestamp);
+		CREATE TABLE containers (
+			name TEXT PRIMARY KEY,
+			container_id TEXT,
+			image TEXT,
+			first_seen INTEGER NOT NULL,
+			last_seen INTEGER NOT NULL
+		);
+	`},
+	{3, "service history", `
+		CREATE TABLE service_metrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			unit TEXT NOT NULL,
+			mem_bytes INTEGER,
+			cpu_ns INTEGER,
+			tasks INTEGER
+		);
+		CREATE INDEX idx_service_metrics_unit_ts ON service_metrics(unit, timestamp);
+		CREATE INDEX idx_service_metrics_ts ON service_metrics(timestamp);
+	`},
+	{4, "process snapshots", `
+		CREATE TABLE process_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			pid INTEGER NOT NULL,
+			name TEXT,
+			username TEXT,
+			cpu_percent REAL,
+			mem_percent REAL,
+			rss INTEGER,
+			cmdline TEXT
+		);
+		CREATE INDEX idx_process_snapshots_ts ON process_snapshots(timestamp);
+	`},
+	{5, "traffic accounting", `
+		CREATE TABLE traffic_daily (
+			day TEXT NOT NULL,
+			iface TEXT NOT NULL,
+			rx INTEGER NOT NULL DEFAULT 0,
+			tx INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, iface)
+		);
+		CREATE TABLE traffic_counters (
+			iface TEXT PRIMARY KEY,
+			boot_time INTEGER NOT NULL,
+			rx INTEGER NOT NULL,
+			tx INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		);
+	`},
+	{6, "tcp state history", `
+		CREATE TABLE tcp_states (
+			timestamp INTEGER NOT NULL,
+			established INTEGER NOT NULL DEFAULT 0,
+			syn_sent INTEGER NOT NULL DEFAULT 0,
+			syn_recv INTEGER NOT NULL DEFAULT 0,
+			fin_wait1 INTEGER NOT NULL DEFAULT 0,
+			fin_wait2 INTEGER NOT NULL DEFAULT 0,
+			time_wait INTEGER NOT NULL DEFAULT 0,
+			close INTEGER NOT NULL DEFAULT 0,
+			close_wait INTEGER NOT NULL DEFAULT 0,
+			last_ack INTEGER NOT NULL DEFAULT 0,
+			listen INTEGER NOT NULL DEFAULT 0,
+			closing INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX idx_tcp_states_ts ON tcp_states(timestamp);
+	`},
+	{7, "pressure stall information", `
+		ALTER TABLE metrics ADD COLUMN cpu_pressure REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN mem_pressure REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN io_pressure REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN mem_pressure_full REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN io_pressure_full REAL NOT NULL DEFAULT 0;
+	`},
+	{8, "kernel events", `
+		CREATE TABLE kernel_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			pid INTEGER NOT NULL DEFAULT 0,
+			process TEXT NOT NULL DEFAULT '',
+			device TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL
+		);
+		CREATE INDEX idx_kernel_events_ts ON kernel_events(timestamp);
+	`},
+	{9, "disk io history", `
+		CREATE TABLE disk_io (
+			timestamp INTEGER NOT NULL,
+			device TEXT NOT NULL,
+			read_bps REAL NOT NULL,
+			write_bps REAL NOT NULL,
+			read_iops REAL NOT NULL,
+			write_iops REAL NOT NULL,
+			read_await REAL NOT NULL,
+			write_await REAL NOT NULL,
+			util REAL NOT NULL
+		);
+		CREATE INDEX idx_disk_io_device_ts ON disk_io(device, timestamp);
+		CREATE INDEX idx_disk_io_ts ON disk_io(timestamp);
+	`},
+	{10, "cpu time breakdown", `
+		ALTER TABLE metrics ADD COLUMN cpu_user REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_system REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_nice REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_iowait REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_irq REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_softirq REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_steal REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN cpu_guest REAL NOT NULL DEFAULT 0;
+	`},
+	{11, "pressure avg60", `
+		ALTER TABLE metrics ADD COLUMN cpu_pressure_avg60 REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN mem_pressure_avg60 REAL NOT NULL DEFAULT 0;
+		ALTER TABLE metrics ADD COLUMN io_pressure_avg60 REAL NOT NULL DEFAULT 0;
+	`},
+	{12, "traffic quota alerts", `
+		CREATE TABLE traffic_alerts (
+			cycle TEXT NOT NULL,
+			percent REAL NOT NULL,
+			PRIMARY KEY (cycle, percent)
+		);
+	`},
+}
+
+// LatestSchemaVersion is the version a freshly migrated database ends up at.
+func LatestSchemaVersion() int { return migrations[len(migrations)-1].version }
+
+// SchemaVersion returns the version recorded in schema_version, 0 if the
+// database has never been migrated.
+func SchemaVersion(db *sql.DB) (int, error) {
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='schema_version'`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+	var v int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version),0) FROM schema_version`).Scan(&v)
+	return v, err
+}
+
+// migrate brings db up to LatestSchemaVersion, one transaction per step.
+// Databases created before versioning existed already match version 1,
+// which only uses IF NOT EXISTS, so they are adopted transparently.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, LatestSchemaVersion())
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version,name,applied_at) VALUES (?,?,?)`,
+			m.version, m.name, time.Now().Unix()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if current > 0 {
+			log.Printf("db: migrated schema to version %d (%s)", m.version, m.name)
+		}
+	}
+	return nil
+}
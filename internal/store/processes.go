@@ -0,0 +1,255 @@
+package store
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// ProcessSample is one process as recorded in a top-N snapshot.
+type ProcessSample struct {
+	Timestamp  int64   `json:"timestamp"`
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	Username   string  `json:"username"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemPercent float64 `json:"mem_percent"`
+	MemRSS     uint64  `json:"mem_rss"`
+	Cmdline    string  `json:"cmdline"`
+}
+
+// ProcessUsage aggregates the samples of one process name over a window.
+type ProcessUsage struct {
+	Name      string  `json:"name"`
+	PIDs      []int32 `json:"pids"`
+	Samples   int     `json:"samples"` // snapshots the name appeared in
+	AvgCPU    float64 `json:"avg_cpu_percent"`
+	MaxCPU    float64 `json:"max_cpu_percent"`
+	AvgMem    float64 `json:"avg_mem_percent"`
+	MaxRSS    uint64  `json:"max_mem_rss"`
+	FirstSeen int64   `json:"first_seen"`
+	LastSeen  int64   `json:"last_seen"`
+}
+
+const (
+	// processMemorySamples bounds the memory backend: a day of top-10
+	// snapshots at one per minute, CPU and memory lists merged.
+	processMemorySamples = 28800
+	maxCmdlineLen        = 512
+)
+
+// TopProcesses merges the topN processes by CPU with the topN by memory.
+func TopProcesses(procs []collector.ProcessInfo, topN int) []collector.ProcessInfo {
+	if topN <= 0 || len(procs) <= topN {
+		return procs
+	}
+	byCPU := append([]collector.ProcessInfo(nil), procs...)
+	sort.Slice(byCPU, func(i, j int) bool { return byCPU[i].CPUPercent > byCPU[j].CPUPercent })
+	byMem := append([]collector.ProcessInfo(nil), procs...)
+	sort.Slice(byMem, func(i, j int) bool { return byMem[i].MemRSS > byMem[j].MemRSS })
+
+	seen := map[int32]bool{}
+	var out []collector.ProcessInfo
+	for _, list := range [][]collector.ProcessInfo{byCPU[:topN], byMem[:topN]} {
+		for _, p := range list {
+			if !seen[p.PID] {
+				seen[p.PID] = true
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+func processSample(ts int64, p collector.ProcessInfo) ProcessSample {
+	cmd := p.Cmdline
+	if len(cmd) > maxCmdlineLen {
+		cmd = cmd[:maxCmdlineLen]
+	}
+	return ProcessSample{
+		Timestamp: ts, PID: p.PID, Name: p.Name, Username: p.Username,
+		CPUPercent: p.CPUPercent, MemPercent: float64(p.MemPercent), MemRSS: p.MemRSS, Cmdline: cmd,
+	}
+}
+
+// SnapshotAt picks the latest snapshot taken at or before ts, but no
+// older than maxAge, from samples sorted by timestamp.
+func SnapshotAt(samples []ProcessSample, ts int64, maxAge int64) []ProcessSample {
+	var at int64 = -1
+	for _, s := range samples {
+		if s.Timestamp <= ts && s.Timestamp >= ts-maxAge {
+			at = s.Timestamp
+		}
+	}
+	var out []ProcessSample
+	for _, s := range samples {
+		if s.Timestamp == at {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CPUPercent > out[j].CPUPercent })
+	return out
+}
+
+// AggregateProcesses groups samples by process name. Processes sharing a
+// name in one snapshot are summed, and both AvgCPU and MaxCPU are taken
+// over those per-snapshot totals for the snapshots the name appeared in,
+// so a short spike is visible in MaxCPU while AvgCPU reflects sustained
+// load. sortBy is "cpu" or "mem".
+func AggregateProcesses(samples []ProcessSample, sortBy string) []ProcessUsage {
+	type acc struct {
+		ProcessUsage
+		snapshots      map[int64]float64 // CPU summed over the name's processes
+		pids           map[int32]bool
+		sumCPU, sumMem float64
+	}
+	byName := map[string]*acc{}
+	for _, s := range samples {
+		a, ok := byName[s.Name]
+		if !ok {
+			a = &acc{ProcessUsage: ProcessUsage{Name: s.Name, FirstSeen: s.Timestamp}, snapshots: map[int64]float64{}, pids: map[int32]bool{}}
+			byName[s.Name] = a
+		}
+		// several processes with the same name in one snapshot count once,
+		// with their usage summed
+		a.snapshots[s.Timestamp] += s.CPUPercent
+		a.pids[s.PID] = true
+		a.sumCPU += s.CPUPercent
+		a.sumMem += s.MemPercent
+		if s.MemRSS > a.MaxRSS {
+			a.MaxRSS = s.MemRSS
+		}
+		if s.Timestamp < a.FirstSeen {
+			a.FirstSeen = s.Timestamp
+		}
+		if s.Timestamp > a.LastSeen {
+			a.LastSeen = s.Timestamp
+		}
+	}
+	result := []ProcessUsage{}
+	for _, a := range byName {
+		u := a.ProcessUsage
+		u.Samples = len(a.snapshots)
+		for _, cpu := range a.snapshots {
+			u.MaxCPU = math.Max(u.MaxCPU, cpu)
+		}
+		u.AvgCPU = a.sumCPU / float64(u.Samples)
+		u.AvgMem = a.sumMem / float64(u.Samples)
+		for pid := range a.pids {
+			u.PIDs = append(u.PIDs, pid)
+		}
+		sort.Slice(u.PIDs, func(i, j int) bool { return u.PIDs[i] < u.PIDs[j] })
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if sortBy == "mem" {
+			return result[i].AvgMem > result[j].AvgMem
+		}
+		return result[i].AvgCPU > result[j].AvgCPU
+	})
+	return result
+}
+
+// StartProcessRecorder snapshots the top processes every interval and
+// prunes snapshots older than the configured retention hourly.
+func StartProcessRecorder(st Store, cfg config.ProcessHistoryConfig) {
+	interval := cfg.Interval
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = 72 * time.Hour
+	}
+	topN := cfg.TopN
+	if topN <= 0 {
+		topN = 10
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPrune time.Time
+	for range ticker.C {
+		procs, err := collector.GetProcesses("cpu", "desc", 0)
+		if err != nil {
+			log.Printf("process snapshot: %v", err)
+			continue
+		}
+		if err := st.SaveProcessSnapshot(time.Now().Unix(), TopProcesses(procs, topN)); err != nil {
+			log.Printf("save process snapshot: %v", err)
+		}
+		if time.Since(lastPrune) >= time.Hour {
+			lastPrune = time.Now()
+			if err := st.PruneProcesses(time.Now().Add(-retention).Unix()); err != nil {
+				log.Printf("prune process snapshots: %v", err)
+			}
+		}
+	}
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveProcessSnapshot(ts int64, procs []collector.ProcessInfo) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, p := range procs {
+		ps := processSample(ts, p)
+		if _, err := tx.Exec(`INSERT INTO process_snapshots (timestamp,pid,name,username,cpu_percent,mem_percent,rss,cmdline) VALUES (?,?,?,?,?,?,?,?)`,
+			ts, ps.PID, ps.Name, ps.Username, ps.CPUPercent, ps.MemPercent, ps.MemRSS, ps.Cmdline); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ProcessSamples(from, to int64) ([]ProcessSample, error) {
+	rows, err := s.db.Query(`SELECT timestamp,pid,name,username,cpu_percent,mem_percent,rss,cmdline FROM process_snapshots WHERE timestamp>=? AND timestamp<=? ORDER BY timestamp ASC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ProcessSample
+	for rows.Next() {
+		var p ProcessSample
+		if err := rows.Scan(&p.Timestamp, &p.PID, &p.Name, &p.Username, &p.CPUPercent, &p.MemPercent, &p.MemRSS, &p.Cmdline); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) PruneProcesses(before int64) error {
+	_, err := s.db.Exec(`DELETE FROM process_snapshots WHERE timestamp < ?`, before)
+	return err
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveProcessSnapshot(ts int64, procs []collector.ProcessInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range procs {
+		m.processes.push(processSample(ts, p))
+	}
+	return nil
+}
+
+func (m *Memory) ProcessSamples(from, to int64) ([]ProcessSample, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.processes.filter(func(p ProcessSample) bool { return p.Timestamp >= from && p.Timestamp <= to }), nil
+}
+
+func (m *Memory) PruneProcesses(before int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processes.dropWhile(func(p ProcessSample) bool { return p.Timestamp < before })
+	return nil
+}
@@ -0,0 +1,30 @@
+package store
+
+import "testing"
+
+func TestAggregateProcessesSameName(t *testing.T) {
+	samples := []ProcessSample{
+		// two workers in the first snapshot, one in the second, none in the third
+		{Timestamp: 10, PID: 1, Name: "php-fpm", CPUPercent: 30},
+		{Timestamp: 10, PID: 2, Name: "php-fpm", CPUPercent: 30},
+		{Timestamp: 20, PID: 1, Name: "php-fpm", CPUPercent: 20},
+		{Timestamp: 20, PID: 3, Name: "nginx", CPUPercent: 5},
+		{Timestamp: 30, PID: 3, Name: "nginx", CPUPercent: 15},
+	}
+	got := AggregateProcesses(samples, "cpu")
+	if len(got) != 2 || got[0].Name != "php-fpm" {
+		t.Fatalf("got %+v", got)
+	}
+	for _, tc := range []struct {
+		u        ProcessUsage
+		samples  int
+		avg, max float64
+	}{
+		{got[0], 2, 40, 60},
+		{got[1], 2, 10, 15},
+	} {
+		if tc.u.Samples != tc.samples || tc.u.AvgCPU != tc.avg || tc.u.MaxCPU != tc.max {
+			t.Errorf("%s: samples %d avg %v max %v, want %d %v %v", tc.u.Name, tc.u.Samples, tc.u.AvgCPU, tc.u.MaxCPU, tc.samples, tc.avg, tc.max)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+// ServicePoint is one sample of a systemd unit. CPUNsec is the cumulative
+// CPUUsageNSec; CPUPercent is derived from consecutive samples by
+// ServiceRates (100 = one core fully busy).
+type ServicePoint struct {
+	Timestamp  int64   `json:"timestamp"`
+	MemBytes   uint64  `json:"memory_bytes"`
+	CPUNsec    uint64  `json:"cpu_ns"`
+	Tasks      uint64  `json:"tasks"`
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+const serviceMemoryPoints = 2880
+
+// SelectServices keeps the active units listed in units, or every active
+// unit when units is empty.
+func SelectServices(svcs []collector.SystemdService, units []string) []collector.SystemdService {
+	want := map[string]bool{}
+	for _, u := range units {
+		want[u] = true
+	}
+	var out []collector.SystemdService
+	for _, s := range svcs {
+		if s.Active != "active" {
+			continue
+		}
+		if len(want) > 0 && !want[s.Unit] {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// servicePoint treats systemd's "unset" value (UINT64_MAX, printed by
+// older versions instead of [not set]) as 0; SQLite cannot store it.
+func servicePoint(ts int64, s collector.SystemdService) ServicePoint {
+	unset := func(v uint64) uint64 {
+		if v == math.MaxUint64 {
+			return 0
+		}
+		return v
+	}
+	tasks, _ := strconv.ParseUint(s.TasksCurrent, 10, 64)
+	return ServicePoint{Timestamp: ts, MemBytes: unset(s.MemoryCurrent), CPUNsec: unset(s.CPUUsageNsec), Tasks: unset(tasks)}
+}
+
+// ServiceRates converts the cumulative CPU time into utilisation per
+// interval. A counter reset (unit restarted) yields 0 for that interval.
+func ServiceRates(points []ServicePoint) {
+	for i := 1; i < len(points); i++ {
+		p, prev := &points[i], points[i-1]
+		wall := float64(p.Timestamp-prev.Timestamp) * 1e9
+		if wall <= 0 || p.CPUNsec < prev.CPUNsec {
+			continue
+		}
+		p.CPUPercent = float64(p.CPUNsec-prev.CPUNsec) / wall * 100
+	}
+}
+
+// MemoryGrowth fits a least-squares line through memory usage and returns
+// its slope in bytes per hour. A steady positive slope over a long window
+// is the typical signature of a leak.
+func MemoryGrowth(points []ServicePoint) float64 {
+	n := float64(len(points))
+	if n < 2 {
+		return 0
+	}
+	t0 := points[0].Timestamp
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x := float64(p.Timestamp-t0) / 3600
+		y := float64(p.MemBytes)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) SaveServiceStats(ts int64, services []collector.SystemdService) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		if svc.Active != "active" {
+			continue
+		}
+		p := servicePoint(ts, svc)
+		if _, err := tx.Exec(`INSERT INTO service_metrics (timestamp,unit,mem_bytes,cpu_ns,tasks) VALUES (?,?,?,?,?)`,
+			ts, svc.Unit, p.MemBytes, p.CPUNsec, p.Tasks); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ServiceHistory(unit string, from, to int64) ([]ServicePoint, error) {
+	rows, err := s.db.Query(`SELECT timestamp,mem_bytes,cpu_ns,tasks FROM service_metrics WHERE unit=? AND timestamp>=? AND timestamp<=? ORDER BY timestamp ASC`, unit, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ServicePoint
+	for rows.Next() {
+		var p ServicePoint
+		if err := rows.Scan(&p.Timestamp, &p.MemBytes, &p.CPUNsec, &p.Tasks); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) SaveServiceStats(ts int64, services []collector.SystemdService) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, svc := range services {
+		if svc.Active != "active" {
+			continue
+		}
+		r, ok := m.services[svc.Unit]
+		if !ok {
+			r = newRing[ServicePoint](serviceMemoryPoints)
+			m.services[svc.Unit] = r
+		}
+		r.push(servicePoint(ts, svc))
+	}
+	return nil
+}
+
+func (m *Memory) ServiceHistory(unit string, from, to int64) ([]ServicePoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.services[unit]
+	if !ok {
+		return nil, nil
+	}
+	points := r.filter(func(p ServicePoint) bool { return p.Timestamp >= from && p.Timestamp <= to })
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points, nil
+}
+
+func (m *Memory) pruneServices(before int64) {
+	for unit, r := range m.services {
+		r.dropWhile(func(p ServicePoint) bool { return p.Timestamp < before })
+		if r.n == 0 {
+			delete(m.services, unit)
+		}
+	}
+}
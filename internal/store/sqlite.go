@@ -0,0 +1,342 @@
+package store
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+// maxPending bounds the write buffer if the database stays unwritable;
+// beyond it the oldest buffered samples are dropped.
+const maxPending = 10000
+
+// SQLite is the default backend, keeping history in a single database file.
+// Samples are buffered in memory and written in one transaction per flush
+// interval, which keeps SD cards from seeing a tiny write every tick.
+type SQLite struct {
+	db   *sql.DB
+	path string
+
+	flushMu  sync.Mutex // one Flush at a time
+	mu       sync.Mutex
+	pending  []MetricPoint
+	flushing []MetricPoint // batch being written, still served to readers
+	stats    WriteStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal=WAL&_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db, path: path, stats: WriteStats{Backend: "sqlite"}}, nil
+}
+
+// OpenReadOnly opens an existing database for offline tools such as
+// "gopanel export" without touching the schema.
+func OpenReadOnly(path string) (*SQLite, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := openReadOnlyDB(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{db: db, path: path, stats: WriteStats{Backend: "sqlite"}}, nil
+}
+
+func openReadOnlyDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	return db, db.Ping()
+}
+
+// StartFlusher switches SaveMetrics to buffered mode, writing the buffer
+// every interval. Without it every sample is written immediately.
+func (s *SQLite) StartFlusher(interval time.Duration) {
+	if interval <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("store flush: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *SQLite) Path() string { return s.path }
+
+// Close writes out anything still buffered before closing the database.
+func (s *SQLite) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	if err := s.Flush(); err != nil {
+		log.Printf("store flush on close: %v", err)
+	}
+	return s.db.Close()
+}
+
+func (s *SQLite) SaveMetrics(snap collector.MetricsSnapshot) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, metricPoint(snap))
+	if n := len(s.pending) - maxPending; n > 0 {
+		s.pending = s.pending[n:]
+		s.stats.Dropped += uint64(n)
+	}
+	buffered := s.stop != nil
+	s.mu.Unlock()
+	if buffered {
+		return nil
+	}
+	return s.Flush()
+}
+
+// Flush writes all buffered samples in a single transaction. On failure
+// the samples are put back so the next flush retries them. Until the
+// commit, readers keep getting the batch from memory.
+func (s *SQLite) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	batch := s.pending
+	s.pending, s.flushing = nil, batch
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	err := s.insertMetrics(batch)
+	elapsed := float64(time.Since(start).Microseconds()) / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushing = nil
+	if err != nil {
+		s.stats.FlushErrors++
+		s.pending = append(batch, s.pending...)
+		if n := len(s.pending) - maxPending; n > 0 {
+			s.pending = s.pending[n:]
+			s.stats.Dropped += uint64(n)
+		}
+		return err
+	}
+	st := &s.stats
+	st.Flushes++
+	st.LastFlushAt = time.Now().Unix()
+	st.LastFlushRows = len(batch)
+	st.LastFlushMs = elapsed
+	if elapsed > st.MaxFlushMs {
+		st.MaxFlushMs = elapsed
+	}
+	if st.Flushes == 1 {
+		st.AvgFlushMs = elapsed
+	} else {
+		st.AvgFlushMs = st.AvgFlushMs*0.9 + elapsed*0.1
+	}
+	return nil
+}
+
+func (s *SQLite) insertMetrics(batch []MetricPoint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO metrics (timestamp,cpu_percent,mem_percent,disk_percent,net_recv,net_sent,cpu_pressure,mem_pressure,io_pressure,mem_pressure_full,io_pressure_full,cpu_user,cpu_system,cpu_nice,cpu_iowait,cpu_irq,cpu_softirq,cpu_steal,cpu_guest,cpu_pressure_avg60,mem_pressure_avg60,io_pressure_avg60) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range batch {
+		if _, err := stmt.Exec(p.Timestamp, p.CPU, p.Memory, p.Disk, p.NetRecv, p.NetSent,
+			p.CPUPressure, p.MemPressure, p.IOPressure, p.MemPressureFull, p.IOPressureFull,
+			p.CPUUser, p.CPUSystem, p.CPUNice, p.CPUIowait, p.CPUIrq, p.CPUSoftirq, p.CPUSteal, p.CPUGuest,
+			p.CPUPressure60, p.MemPressure60, p.IOPressure60); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// metricsPageSize bounds how many rows MetricsRange reads per query. The
+// pool has a single connection, so no result set is held open while fn
+// runs: a slow consumer such as an export to a stalled client would
+// otherwise block the collector and every other query.
+const metricsPageSize = 1000
+
+type metricRow struct {
+	id int64
+	p  MetricPoint
+}
+
+// metricsPage reads up to metricsPageSize rows after (ts, id) in
+// (timestamp, rowid) order.
+func (s *SQLite) metricsPage(ts, id, to int64) ([]metricRow, error) {
+	rows, err := s.db.Query(`SELECT rowid,timestamp,cpu_percent,mem_percent,disk_percent,net_recv,net_sent,cpu_pressure,mem_pressure,io_pressure,mem_pressure_full,io_pressure_full,cpu_user,cpu_system,cpu_nice,cpu_iowait,cpu_irq,cpu_softirq,cpu_steal,cpu_guest,cpu_pressure_avg60,mem_pressure_avg60,io_pressure_avg60 FROM metrics
+		WHERE timestamp>=? AND timestamp<=? AND (timestamp>? OR rowid>?) ORDER BY timestamp ASC, rowid ASC LIMIT ?`, ts, to, ts, id, metricsPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var page []metricRow
+	for rows.Next() {
+		var r metricRow
+		p := &r.p
+		if err := rows.Scan(&r.id, &p.Timestamp, &p.CPU, &p.Memory, &p.Disk, &p.NetRecv, &p.NetSent,
+			&p.CPUPressure, &p.MemPressure, &p.IOPressure, &p.MemPressureFull, &p.IOPressureFull,
+			&p.CPUUser, &p.CPUSystem, &p.CPUNice, &p.CPUIowait, &p.CPUIrq, &p.CPUSoftirq, &p.CPUSteal, &p.CPUGuest,
+			&p.CPUPressure60, &p.MemPressure60, &p.IOPressure60); err != nil {
+			return nil, err
+		}
+		page = append(page, r)
+	}
+	return page, rows.Err()
+}
+
+// MetricsRange reads flushed rows from the database page by page and then
+// appends any still-buffered samples, which are always newer, so readers
+// never lag a flush interval behind.
+func (s *SQLite) MetricsRange(from, to int64, fn func(MetricPoint) error) error {
+	// taking the buffer first and reading rows only up to its oldest
+	// sample means a flush committing meanwhile neither hides nor repeats
+	// a sample
+	s.mu.Lock()
+	buffered := append(append([]MetricPoint(nil), s.flushing...), s.pending...)
+	s.mu.Unlock()
+	dbTo := to
+	if len(buffered) > 0 && buffered[0].Timestamp <= dbTo {
+		dbTo = buffered[0].Timestamp - 1
+	}
+
+	ts, id := from, int64(0)
+	for {
+		page, err := s.metricsPage(ts, id, dbTo)
+		if err != nil {
+			return err
+		}
+		for _, r := range page {
+			if err := fn(r.p); err != nil {
+				return err
+			}
+		}
+		if len(page) < metricsPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		ts, id = last.p.Timestamp, last.id
+	}
+
+	for _, p := range buffered {
+		if p.Timestamp < from || p.Timestamp > to {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) SaveAlert(a Alert) error {
+	_, err := s.db.Exec(`INSERT INTO alerts (timestamp,type,value,threshold,message) VALUES (?,?,?,?,?)`,
+		a.Timestamp, a.Type, a.Value, a.Threshold, a.Message)
+	return err
+}
+
+func (s *SQLite) Alerts(limit int) ([]Alert, error) {
+	rows, err := s.db.Query(`SELECT id,timestamp,type,value,threshold,message FROM alerts ORDER BY timestamp DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []Alert
+	for rows.Next() {
+		var a Alert
+		rows.Scan(&a.ID, &a.Timestamp, &a.Type, &a.Value, &a.Threshold, &a.Message)
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// pruneQueries run in order on every Prune with the cutoff as argument.
+var pruneQueries = []string{
+	`DELETE FROM metrics WHERE timestamp < ?`,
+	`DELETE FROM container_metrics WHERE timestamp < ?`,
+	`DELETE FROM containers WHERE last_seen < ?`,
+	`DELETE FROM service_metrics WHERE timestamp < ?`,
+	`DELETE FROM process_snapshots WHERE timestamp < ?`,
+	`DELETE FROM tcp_states WHERE timestamp < ?`,
+	`DELETE FROM kernel_events WHERE timestamp < ?`,
+	`DELETE FROM disk_io WHERE timestamp < ?`,
+}
+
+func (s *SQLite) Prune(before int64) error {
+	start := time.Now()
+	var total int64
+	for _, q := range pruneQueries {
+		res, err := s.db.Exec(q, before)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	s.mu.Lock()
+	s.stats.LastPruneAt = time.Now().Unix()
+	s.stats.LastPruneRows = total
+	s.stats.LastPruneMs = float64(time.Since(start).Microseconds()) / 1000
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *SQLite) Stats() WriteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.Pending = len(s.pending)
+	return st
+}
+
+func (s *SQLite) SchemaVersion() (int, error) { return SchemaVersion(s.db) }
+
+// MigratedAt returns when migration version was applied to this database,
+// 0 if it never was.
+func (s *SQLite) MigratedAt(version int) (int64, error) {
+	var at int64
+	err := s.db.QueryRow(`SELECT applied_at FROM schema_version WHERE version=?`, version).Scan(&at)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return at, err
+}
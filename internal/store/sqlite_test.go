@@ -0,0 +1,110 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMetricsRangePages(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// more than two pages, with several rows per timestamp so paging must
+	// not skip or repeat rows that share the boundary timestamp
+	var batch []MetricPoint
+	for i := 0; i < 2*metricsPageSize+500; i++ {
+		batch = append(batch, MetricPoint{Timestamp: int64(1000 + i/3), CPU: float64(i)})
+	}
+	if err := s.insertMetrics(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []MetricPoint
+	err = s.MetricsRange(1000, 2000, func(p MetricPoint) error {
+		got = append(got, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(batch) {
+		t.Fatalf("got %d rows, want %d", len(got), len(batch))
+	}
+	for i, p := range got {
+		if p.CPU != float64(i) {
+			t.Fatalf("row %d has cpu %g, rows out of order or repeated", i, p.CPU)
+		}
+	}
+
+	n := 0
+	s.MetricsRange(1100, 1199, func(MetricPoint) error { n++; return nil })
+	if n != 300 {
+		t.Errorf("sub-range returned %d rows, want 300", n)
+	}
+}
+
+func TestTrafficAlertedSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkTrafficAlerted("2026-10-01", []float64{80, 90}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkTrafficAlerted("2026-10-01", []float64{90}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if s, err = OpenSQLite(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.TrafficAlerted("2026-10-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[80] || !got[90] {
+		t.Errorf("alerted = %v, want 80 and 90", got)
+	}
+	if got, _ := s.TrafficAlerted("2026-11-01"); len(got) != 0 {
+		t.Errorf("next cycle alerted = %v, want none", got)
+	}
+}
+
+func TestMetricsRangeDuringFlush(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.insertMetrics([]MetricPoint{{Timestamp: 100}, {Timestamp: 101}}); err != nil {
+		t.Fatal(err)
+	}
+	batch := []MetricPoint{{Timestamp: 102}, {Timestamp: 103}}
+	read := func() []int64 {
+		var got []int64
+		s.MetricsRange(0, 200, func(p MetricPoint) error {
+			got = append(got, p.Timestamp)
+			return nil
+		})
+		return got
+	}
+
+	// batch taken out of pending, transaction not committed yet
+	s.flushing, s.pending = batch, []MetricPoint{{Timestamp: 104}}
+	if got := read(); len(got) != 5 {
+		t.Errorf("before commit: got %v, want 100..104", got)
+	}
+	// committed, flushing not cleared yet
+	if err := s.insertMetrics(batch); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); len(got) != 5 {
+		t.Errorf("after commit: got %v, want 100..104 once each", got)
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+// StorageAlerts lists the problems in s: degraded or inactive md arrays,
+// LVM groups and volumes with missing PVs, thin pools filling up and ZFS
+// pools that are not ONLINE.
+func StorageAlerts(s collector.StorageStatus, thinPoolPercent float64) []Alert {
+	var out []Alert
+	for _, md := range s.MD {
+		switch {
+		case md.Degraded:
+			msg := fmt.Sprintf("软 RAID %s（%s）已降级：%d/%d 个设备正常 [%s]", md.Name, md.Level, md.Active, md.Total, md.Status)
+			for _, m := range md.Members {
+				if m.Faulty {
+					msg += "，故障盘 " + m.Device
+				}
+			}
+			if md.Sync != nil && !md.Sync.Pending {
+				msg += fmt.Sprintf("，%s %.1f%%", md.Sync.Action, md.Sync.Percent)
+			}
+			out = append(out, Alert{Type: "RAID 降级(" + md.Name + ")", Value: float64(md.Active), Threshold: float64(md.Total), Message: msg})
+		case md.State == "inactive" && len(md.Members) > 0:
+			out = append(out, Alert{Type: "RAID 未激活(" + md.Name + ")", Message: fmt.Sprintf("软 RAID %s 未激活", md.Name)})
+		}
+	}
+	for _, vg := range s.VGs {
+		if vg.Partial {
+			out = append(out, Alert{Type: "LVM 缺失 PV(" + vg.Name + ")", Message: fmt.Sprintf("卷组 %s 缺少物理卷", vg.Name)})
+		}
+	}
+	for _, lv := range s.LVs {
+		name := lv.VG + "/" + lv.Name
+		if lv.Partial {
+			out = append(out, Alert{Type: "LVM 卷不完整(" + name + ")", Message: fmt.Sprintf("逻辑卷 %s 不完整（attr %s）", name, lv.Attr)})
+		}
+		if !lv.ThinPool || thinPoolPercent <= 0 {
+			continue
+		}
+		if used := max(lv.DataPercent, lv.MetadataPercent); used >= thinPoolPercent {
+			out = append(out, Alert{
+				Type: "LVM 精简池(" + name + ")", Value: used, Threshold: thinPoolPercent,
+				Message: fmt.Sprintf("精简池 %s 数据 %.1f%%、元数据 %.1f%%，超过阈值 %.0f%%", name, lv.DataPercent, lv.MetadataPercent, thinPoolPercent),
+			})
+		}
+	}
+	for _, p := range s.Pools {
+		if p.Health != "ONLINE" {
+			msg := fmt.Sprintf("ZFS 池 %s 状态 %s", p.Name, p.Health)
+			if p.Status != "" {
+				msg += "：" + p.Status
+			}
+			out = append(out, Alert{Type: "ZFS 池异常(" + p.Name + ")", Message: msg})
+		}
+	}
+	return out
+}
+
+// StartStorageWatch checks md, LVM and ZFS every minute and raises the
+// StorageAlerts, each at most once per cooldown.
+func StartStorageWatch(st Store, cfg *config.Config) {
+	check := func() {
+		for _, a := range StorageAlerts(collector.GetStorageStatus(), cfg.Alert.Storage.ThinPoolPercent) {
+			RaiseAlert(st, cfg, a)
+		}
+	}
+	check()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		check()
+	}
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gopanel/gopanel/internal/collector"
+)
+
+func storageFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "collector", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStorageAlerts(t *testing.T) {
+	pools := collector.ParseZpoolList(storageFixture(t, "zpool_list"))
+	status := collector.ParseZpoolStatus(storageFixture(t, "zpool_status"))
+	for i := range pools {
+		if st, ok := status[pools[i].Name]; ok {
+			pools[i].Status = st.Status
+		}
+	}
+
+	tests := []struct {
+		name   string
+		status collector.StorageStatus
+		want   map[string]string // alert type → fragment of the message
+	}{
+		{
+			"healthy md", collector.StorageStatus{MD: collector.ParseMdstat(storageFixture(t, "mdstat_healthy"))},
+			map[string]string{},
+		},
+		{
+			"resync and delayed resync are not alerts",
+			collector.StorageStatus{MD: collector.ParseMdstat(storageFixture(t, "mdstat_resync"))},
+			map[string]string{},
+		},
+		{
+			"degraded md", collector.StorageStatus{MD: collector.ParseMdstat(storageFixture(t, "mdstat_degraded"))},
+			map[string]string{
+				"RAID 降级(md1)": "1/2 个设备正常 [U_]，故障盘 sdb2",
+				"RAID 降级(md2)": "recovery 27.5%",
+			},
+		},
+		{
+			"lvm", collector.StorageStatus{
+				VGs: collector.ParseVGs(storageFixture(t, "vgs")),
+				LVs: collector.ParseLVs(storageFixture(t, "lvs_thin")),
+			},
+			map[string]string{
+				"LVM 缺失 PV(vg1)":     "卷组 vg1 缺少物理卷",
+				"LVM 卷不完整(vg1/data)": "attr -wi-a---p-",
+				"LVM 精简池(vg0/pool0)": "数据 92.5%",
+				"LVM 精简池(vg0/pool1)": "元数据 95.5%",
+			},
+		},
+		{
+			"zfs", collector.StorageStatus{Pools: pools},
+			map[string]string{
+				"ZFS 池异常(tank)":   "状态 DEGRADED：One or more devices could not be used",
+				"ZFS 池异常(backup)": "状态 UNAVAIL：One or more devices could not be opened",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := map[string]string{}
+			for _, a := range StorageAlerts(tc.status, 90) {
+				got[a.Type] = a.Message
+			}
+			var types []string
+			for typ := range got {
+				types = append(types, typ)
+			}
+			sort.Strings(types)
+			if len(got) != len(tc.want) {
+				t.Errorf("got alerts %q, want %d", types, len(tc.want))
+			}
+			for typ, fragment := range tc.want {
+				msg, ok := got[typ]
+				if !ok {
+					t.Errorf("missing alert %q (got %q)", typ, types)
+				} else if !strings.Contains(msg, fragment) {
+					t.Errorf("%s: message %q does not contain %q", typ, msg, fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageAlertsThinPoolThreshold(t *testing.T) {
+	lvs := collector.ParseLVs(storageFixture(t, "lvs_thin"))
+	for _, tc := range []struct {
+		threshold float64
+		want      int
+	}{{0, 0}, {99, 0}, {95, 1}, {90, 2}} {
+		n := 0
+		for _, a := range StorageAlerts(collector.StorageStatus{LVs: lvs}, tc.threshold) {
+			if strings.HasPrefix(a.Type, "LVM 精简池") {
+				n++
+			}
+		}
+		if n != tc.want {
+			t.Errorf("threshold %g: %d thin pool alerts, want %d", tc.threshold, n, tc.want)
+		}
+	}
+}
@@ -2,49 +2,179 @@ package store
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/gopanel/gopanel/internal/collector"
 	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/sink"
 	"github.com/gopanel/gopanel/internal/websocket"
 )
 
-func Init(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path+"?_journal=WAL&_timeout=5000")
-	if err != nil {
-		return nil, err
-	}
-	db.SetMaxOpenConns(1)
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS metrics (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			timestamp INTEGER NOT NULL,
-			cpu_percent REAL,
-			mem_percent REAL,
-			disk_percent REAL,
-			net_recv INTEGER,
-			net_sent INTEGER
-		);
-		CREATE INDEX IF NOT EXISTS idx_metrics_ts ON metrics(timestamp);
-		CREATE TABLE IF NOT EXISTS alerts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			timestamp INTEGER NOT NULL,
-			type TEXT NOT NULL,
-			value REAL,
-			threshold REAL,
-			message TEXT
-		);
-	`)
-	return db, err
+// MetricPoint is one row of host-level history.
+type MetricPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	CPU       float64 `json:"cpu"`
+	Memory    float64 `json:"memory"`
+	Disk      float64 `json:"disk"`
+	NetRecv   uint64  `json:"net_recv"`
+	NetSent   uint64  `json:"net_sent"`
+	// PSI "some" avg10 per resource and "full" avg10 for memory and io,
+	// all 0 on kernels without pressure information
+	CPUPressure     float64 `json:"cpu_pressure"`
+	MemPressure     float64 `json:"mem_pressure"`
+	IOPressure      float64 `json:"io_pressure"`
+	MemPressureFull float64 `json:"mem_pressure_full"`
+	IOPressureFull  float64 `json:"io_pressure_full"`
+	// PSI "some" avg60, the window the pressure alerts check
+	CPUPressure60 float64 `json:"cpu_pressure_avg60"`
+	MemPressure60 float64 `json:"mem_pressure_avg60"`
+	IOPressure60  float64 `json:"io_pressure_avg60"`
+	// CPU time breakdown in percent over the sample interval
+	CPUUser    float64 `json:"cpu_user"`
+	CPUSystem  float64 `json:"cpu_system"`
+	CPUNice    float64 `json:"cpu_nice"`
+	CPUIowait  float64 `json:"cpu_iowait"`
+	CPUIrq     float64 `json:"cpu_irq"`
+	CPUSoftirq float64 `json:"cpu_softirq"`
+	CPUSteal   float64 `json:"cpu_steal"`
+	CPUGuest   float64 `json:"cpu_guest"`
+}
+
+type Alert struct {
+	ID        int64   `json:"id"`
+	Timestamp int64   `json:"timestamp"`
+	Type      string  `json:"type"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Message   string  `json:"message"`
+}
+
+// WriteStats describes the backend's own write path.
+type WriteStats struct {
+	Backend       string  `json:"backend"`
+	Pending       int     `json:"pending"` // samples buffered, not yet written
+	Dropped       uint64  `json:"dropped"` // samples discarded because the buffer overflowed
+	Flushes       uint64  `json:"flushes"`
+	FlushErrors   uint64  `json:"flush_errors"`
+	LastFlushAt   int64   `json:"last_flush_at"`
+	LastFlushRows int     `json:"last_flush_rows"`
+	LastFlushMs   float64 `json:"last_flush_ms"`
+	AvgFlushMs    float64 `json:"avg_flush_ms"` // exponentially weighted
+	MaxFlushMs    float64 `json:"max_flush_ms"`
+	LastPruneAt   int64   `json:"last_prune_at"`
+	LastPruneRows int64   `json:"last_prune_rows"`
+	LastPruneMs   float64 `json:"last_prune_ms"`
+}
+
+// Store is the persistence backend for collected history and alerts.
+type Store interface {
+	// SaveMetrics records a snapshot; backends may buffer it.
+	SaveMetrics(snap collector.MetricsSnapshot) error
+	// MetricsRange calls fn for every point with from <= timestamp <= to,
+	// oldest first, stopping at the first error fn returns.
+	MetricsRange(from, to int64, fn func(MetricPoint) error) error
+	SaveAlert(a Alert) error
+	Alerts(limit int) ([]Alert, error)
+
+	// SaveContainerStats records the running containers' resource usage,
+	// keyed by container name so a recreated container continues its series.
+	SaveContainerStats(ts int64, containers []collector.Container) error
+	ContainerHistory(name string, from, to int64) ([]ContainerPoint, error)
+	ContainerSeries() ([]ContainerSeries, error)
+
+	// SaveServiceStats records memory, CPU time and task count of the
+	// given systemd units; inactive units are skipped.
+	SaveServiceStats(ts int64, services []collector.SystemdService) error
+	ServiceHistory(unit string, from, to int64) ([]ServicePoint, error)
+
+	SaveProcessSnapshot(ts int64, procs []collector.ProcessInfo) error
+	// ProcessSamples returns every recorded process sample in [from, to].
+	ProcessSamples(from, to int64) ([]ProcessSample, error)
+	// PruneProcesses has its own cutoff because process snapshots are kept
+	// for a shorter time than the rest of the history.
+	PruneProcesses(before int64) error
+
+	SaveTCPStates(p TCPStatePoint) error
+	TCPStateHistory(from, to int64) ([]TCPStatePoint, error)
+
+	SaveDiskIO(ts int64, devices []collector.BlockIO) error
+	// DiskIOHistory returns the points of one device, or of every device
+	// when device is empty, ordered by timestamp.
+	DiskIOHistory(device string, from, to int64) ([]DiskIOPoint, error)
+
+	SaveKernelEvent(e collector.KernelEvent) error
+	// KernelEvents returns up to limit events in [from, to], newest first,
+	// of the given type or of any type when typ is empty.
+	KernelEvents(from, to int64, typ string, limit int) ([]KernelEvent, error)
+
+	// Traffic accounting is never pruned: the rows are one per interface
+	// per day and monthly totals are built from them.
+	TrafficCounters() (map[string]TrafficCounter, error)
+	// RecordTraffic adds usage to its day buckets and stores the counters
+	// the usage was derived from, atomically.
+	RecordTraffic(usage []TrafficUsage, counters []TrafficCounter) error
+	// TrafficDays returns the daily buckets with from <= day <= to
+	// (both "2006-01-02").
+	TrafficDays(from, to string) ([]TrafficUsage, error)
+	// TrafficAlerted returns the quota percentages already alerted for the
+	// billing cycle starting on cycle ("2006-01-02"), so a restart does
+	// not send them again; MarkTrafficAlerted records more of them.
+	TrafficAlerted(cycle string) (map[float64]bool, error)
+	MarkTrafficAlerted(cycle string, percents []float64) error
+
+	// Prune drops history older than before (unix seconds), including
+	// series of containers not seen since then.
+	Prune(before int64) error
+	Stats() WriteStats
+	Close() error
+}
+
+// Maintainer is implemented by backends that live in a database file and
+// support online backup, restore and housekeeping.
+type Maintainer interface {
+	Path() string
+	SchemaVersion() (int, error)
+	Backup(dest string) error
+	Restore(src string) error
+	Vacuum() error
+	IntegrityCheck() ([]string, error)
 }
 
-func SaveMetrics(db *sql.DB, snap collector.MetricsSnapshot) {
+// Open returns the backend selected by storage.backend.
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.Storage.Backend {
+	case "", "sqlite":
+		s, err := OpenSQLite(cfg.DBPath)
+		if err != nil {
+			return nil, err
+		}
+		s.StartFlusher(cfg.Storage.FlushInterval)
+		return s, nil
+	case "memory":
+		return NewMemory(cfg.Storage.MemoryPoints), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+}
+
+// MetricsHistory returns the points recorded in the last hours.
+func MetricsHistory(st Store, hours int) ([]MetricPoint, error) {
+	since := time.Now().Add(-time.Duration(hours) * time.Hour).Unix()
+	var result []MetricPoint
+	err := st.MetricsRange(since+1, time.Now().Unix(), func(p MetricPoint) error {
+		result = append(result, p)
+		return nil
+	})
+	return result, err
+}
+
+// metricPoint reduces a snapshot to the figures kept in history: the fullest
+// partition and the summed interface counters.
+func metricPoint(snap collector.MetricsSnapshot) MetricPoint {
 	var maxDisk float64
 	for _, p := range snap.Disk.Partitions {
 		if p.UsedPercent > maxDisk {
@@ -56,73 +186,79 @@ func SaveMetrics(db *sql.DB, snap collector.MetricsSnapshot) {
 		totalRecv += iface.BytesRecv
 		totalSent += iface.BytesSent
 	}
-	db.Exec(`INSERT INTO metrics (timestamp,cpu_percent,mem_percent,disk_percent,net_recv,net_sent) VALUES (?,?,?,?,?,?)`,
-		snap.Timestamp, snap.CPU.UsagePercent, snap.Memory.UsedPercent, maxDisk, totalRecv, totalSent)
+	psi, cpu := snap.Pressure, snap.CPU.Breakdown
+	return MetricPoint{
+		Timestamp: snap.Timestamp,
+		CPU:       snap.CPU.UsagePercent,
+		Memory:    snap.Memory.UsedPercent,
+		Disk:      maxDisk,
+		NetRecv:   totalRecv,
+		NetSent:   totalSent,
 
-	// Prune old data (keep 7 days)
-	cutoff := time.Now().Add(-7 * 24 * time.Hour).Unix()
-	db.Exec(`DELETE FROM metrics WHERE timestamp < ?`, cutoff)
-}
+		CPUPressure:     psi.CPU.Some.Avg10,
+		MemPressure:     psi.Memory.Some.Avg10,
+		IOPressure:      psi.IO.Some.Avg10,
+		MemPressureFull: psi.Memory.Full.Avg10,
+		IOPressureFull:  psi.IO.Full.Avg10,
+		CPUPressure60:   psi.CPU.Some.Avg60,
+		MemPressure60:   psi.Memory.Some.Avg60,
+		IOPressure60:    psi.IO.Some.Avg60,
 
-func GetMetricsHistory(db *sql.DB, hours int) ([]map[string]interface{}, error) {
-	since := time.Now().Add(-time.Duration(hours) * time.Hour).Unix()
-	rows, err := db.Query(`SELECT timestamp,cpu_percent,mem_percent,disk_percent FROM metrics WHERE timestamp>? ORDER BY timestamp ASC`, since)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var result []map[string]interface{}
-	for rows.Next() {
-		var ts int64
-		var cpu, mem, disk float64
-		rows.Scan(&ts, &cpu, &mem, &disk)
-		result = append(result, map[string]interface{}{
-			"timestamp": ts, "cpu": cpu, "memory": mem, "disk": disk,
-		})
-	}
-	return result, nil
-}
-
-func GetAlerts(db *sql.DB, limit int) ([]map[string]interface{}, error) {
-	rows, err := db.Query(`SELECT id,timestamp,type,value,threshold,message FROM alerts ORDER BY timestamp DESC LIMIT ?`, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var result []map[string]interface{}
-	for rows.Next() {
-		var id int64
-		var ts int64
-		var atype, msg string
-		var value, threshold float64
-		rows.Scan(&id, &ts, &atype, &value, &threshold, &msg)
-		result = append(result, map[string]interface{}{
-			"id": id, "timestamp": ts, "type": atype, "value": value, "threshold": threshold, "message": msg,
-		})
-	}
-	return result, nil
+		CPUUser:    cpu.User,
+		CPUSystem:  cpu.System,
+		CPUNice:    cpu.Nice,
+		CPUIowait:  cpu.Iowait,
+		CPUIrq:     cpu.Irq,
+		CPUSoftirq: cpu.Softirq,
+		CPUSteal:   cpu.Steal,
+		CPUGuest:   cpu.Guest,
+	}
 }
 
 // cooldown prevents repeated alerts (1 per 10 mins per type)
-var alertCooldown = make(map[string]time.Time)
+var (
+	alertMu       sync.Mutex
+	alertCooldown = make(map[string]time.Time)
+)
 
-func checkAlert(db *sql.DB, cfg *config.Config, alertType string, value, threshold float64) {
+func checkAlert(st Store, cfg *config.Config, alertType string, value, threshold float64) {
 	if threshold <= 0 || value < threshold {
 		return
 	}
-	// Cooldown check
-	if t, ok := alertCooldown[alertType]; ok && time.Since(t) < 10*time.Minute {
+	msg := fmt.Sprintf("%s 使用率 %.1f%% 超过阈值 %.0f%%", alertType, value, threshold)
+	RaiseAlert(st, cfg, Alert{Type: alertType, Value: value, Threshold: threshold, Message: msg})
+}
+
+func checkPressure(st Store, cfg *config.Config, resource string, value, threshold float64) {
+	if threshold <= 0 || value < threshold {
 		return
 	}
-	alertCooldown[alertType] = time.Now()
+	msg := fmt.Sprintf("%s 压力（PSI some avg60）%.1f%% 超过阈值 %g%%", resource, value, threshold)
+	RaiseAlert(st, cfg, Alert{Type: resource + "压力", Value: value, Threshold: threshold, Message: msg})
+}
 
-	msg := fmt.Sprintf("%s 使用率 %.1f%% 超过阈值 %.0f%%", alertType, value, threshold)
-	db.Exec(`INSERT INTO alerts (timestamp,type,value,threshold,message) VALUES (?,?,?,?,?)`,
-		time.Now().Unix(), alertType, value, threshold, msg)
+// RaiseAlert records an alert and posts it to the configured webhook,
+// at most once per 10 minutes per alert type. It reports whether the alert
+// was raised or suppressed by the cooldown.
+func RaiseAlert(st Store, cfg *config.Config, a Alert) bool {
+	alertMu.Lock()
+	if t, ok := alertCooldown[a.Type]; ok && time.Since(t) < 10*time.Minute {
+		alertMu.Unlock()
+		return false
+	}
+	alertCooldown[a.Type] = time.Now()
+	alertMu.Unlock()
 
+	if a.Timestamp == 0 {
+		a.Timestamp = time.Now().Unix()
+	}
+	if err := st.SaveAlert(a); err != nil {
+		log.Printf("save alert: %v", err)
+	}
 	if cfg.Alert.Webhook != "" {
-		go sendWebhook(cfg.Alert.Webhook, alertType, value, threshold, msg)
+		go sendWebhook(cfg.Alert.Webhook, a.Type, a.Value, a.Threshold, a.Message)
 	}
+	return true
 }
 
 func sendWebhook(webhookURL, alertType string, value, threshold float64, msg string) {
@@ -139,31 +275,68 @@ func sendWebhook(webhookURL, alertType string, value, threshold float64, msg str
 	resp.Body.Close()
 }
 
-func StartCollector(db *sql.DB, hub *websocket.Hub, interval time.Duration) {
+func StartCollector(st Store, hub *websocket.Hub, sinks *sink.Manager, interval time.Duration) {
 	// Ensure minimum 2s interval to keep resource usage low
 	if interval < 2*time.Second {
 		interval = 2 * time.Second
 	}
+	// own sampler so every point covers exactly one tick, whoever else
+	// reads the CPU in between; the first Sample (since boot) only sets
+	// the baseline
+	sampler := &collector.CPUSampler{}
+	sampler.Sample()
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	for range ticker.C {
-		snap := collector.CollectAll()
-		SaveMetrics(db, snap)
+		snap := collector.CollectAll(sampler)
+		if err := st.SaveMetrics(snap); err != nil {
+			log.Printf("save metrics: %v", err)
+		}
+		sinks.Publish(snap)
 		hub.Broadcast("metrics", snap)
 	}
 }
 
-func StartAlertChecker(db *sql.DB, cfg *config.Config) {
+// StartMaintenance prunes history older than retention once at startup and
+// then hourly, keeping deletes off the per-sample write path.
+func StartMaintenance(st Store, retention time.Duration) {
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	prune := func() {
+		if err := st.Prune(time.Now().Add(-retention).Unix()); err != nil {
+			log.Printf("store prune: %v", err)
+		}
+	}
+	prune()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		prune()
+	}
+}
+
+func StartAlertChecker(st Store, cfg *config.Config) {
+	sampler := &collector.CPUSampler{}
+	sampler.Sample()
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 	for range ticker.C {
-		cpu := collector.GetCPUStats()
+		cpu := sampler.Stats()
 		mem := collector.GetMemoryStats()
 		disk := collector.GetDiskStats()
-		checkAlert(db, cfg, "CPU", cpu.UsagePercent, cfg.Alert.CPU)
-		checkAlert(db, cfg, "内存", mem.UsedPercent, cfg.Alert.Memory)
+		checkAlert(st, cfg, "CPU", cpu.UsagePercent, cfg.Alert.CPU)
+		checkAlert(st, cfg, "内存", mem.UsedPercent, cfg.Alert.Memory)
 		for _, p := range disk.Partitions {
-			checkAlert(db, cfg, "磁盘("+p.Mountpoint+")", p.UsedPercent, cfg.Alert.Disk)
+			checkAlert(st, cfg, "磁盘("+p.Mountpoint+")", p.UsedPercent, cfg.Alert.Disk)
+			checkAlert(st, cfg, "inode("+p.Mountpoint+")", p.InodesUsedPercent, cfg.Alert.Inodes)
+		}
+		psi := collector.GetPressure()
+		if psi.Available {
+			pc := cfg.Alert.Pressure
+			checkPressure(st, cfg, "CPU", psi.CPU.Some.Avg60, pc.CPU)
+			checkPressure(st, cfg, "内存", psi.Memory.Some.Avg60, pc.Memory)
+			checkPressure(st, cfg, "IO", psi.IO.Some.Avg60, pc.IO)
 		}
 	}
 }
@@ -0,0 +1,417 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+const dayLayout = "2006-01-02"
+
+// TrafficUsage is the transfer of one interface on one local day.
+type TrafficUsage struct {
+	Day   string `json:"day"`
+	Iface string `json:"iface"`
+	Rx    uint64 `json:"rx"`
+	Tx    uint64 `json:"tx"`
+}
+
+// TrafficCounter is the last kernel counter reading of an interface. It is
+// persisted so that usage keeps accumulating across GoPanel restarts.
+type TrafficCounter struct {
+	Iface     string
+	BootTime  uint64
+	Rx, Tx    uint64
+	UpdatedAt int64
+}
+
+// TrafficTotal sums usage over a period (a day or a billing cycle).
+type TrafficTotal struct {
+	Period string `json:"period"`
+	Start  string `json:"start"`
+	End    string `json:"end"` // exclusive
+	Rx     uint64 `json:"rx"`
+	Tx     uint64 `json:"tx"`
+	Total  uint64 `json:"total"`
+}
+
+// TrafficCycle is the current billing cycle measured against the quota.
+type TrafficCycle struct {
+	TrafficTotal
+	Billed      uint64         `json:"billed"` // per quota_direction
+	QuotaBytes  uint64         `json:"quota_bytes"`
+	UsedPercent float64        `json:"used_percent"`
+	Projected   uint64         `json:"projected"` // billed bytes at cycle end at the current average rate
+	Interfaces  []TrafficTotal `json:"interfaces"`
+}
+
+// bootTimeSlack absorbs the jitter of boot times derived from uptime.
+const bootTimeSlack = 60
+
+// trafficDelta returns the transfer since prev. A changed boot time or a
+// counter that went backwards means the counter restarted from zero, so
+// the whole current value is new traffic.
+func trafficDelta(prev TrafficCounter, boot, rx, tx uint64) (uint64, uint64) {
+	rebooted := boot > prev.BootTime+bootTimeSlack || prev.BootTime > boot+bootTimeSlack
+	if rebooted || rx < prev.Rx || tx < prev.Tx {
+		return rx, tx
+	}
+	return rx - prev.Rx, tx - prev.Tx
+}
+
+// CycleStart returns the start of the billing cycle containing t.
+func CycleStart(t time.Time, startDay int) time.Time {
+	if startDay < 1 || startDay > 28 {
+		startDay = 1
+	}
+	y, m, d := t.Date()
+	if d < startDay {
+		m--
+	}
+	return time.Date(y, m, startDay, 0, 0, 0, 0, t.Location())
+}
+
+// SumTraffic totals the rows of one interface, or of all when iface is "".
+func SumTraffic(rows []TrafficUsage, iface, start, end string) TrafficTotal {
+	t := TrafficTotal{Start: start, End: end}
+	for _, r := range rows {
+		if r.Day < start || r.Day >= end || (iface != "" && r.Iface != iface) {
+			continue
+		}
+		t.Rx += r.Rx
+		t.Tx += r.Tx
+	}
+	t.Total = t.Rx + t.Tx
+	return t
+}
+
+// TrafficDaily returns one total per day for the last days days, oldest
+// first, including days without traffic.
+func TrafficDaily(st Store, iface string, days int, now time.Time) ([]TrafficTotal, error) {
+	if days <= 0 {
+		days = 30
+	}
+	first := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, now.Location())
+	rows, err := st.TrafficDays(first.Format(dayLayout), now.Format(dayLayout))
+	if err != nil {
+		return nil, err
+	}
+	var out []TrafficTotal
+	for d := first; !d.After(now); d = d.AddDate(0, 0, 1) {
+		day := d.Format(dayLayout)
+		t := SumTraffic(rows, iface, day, d.AddDate(0, 0, 1).Format(dayLayout))
+		t.Period = day
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// TrafficMonthly returns one total per billing cycle for the last months
+// cycles, oldest first. Cycles are labelled by the month they start in.
+func TrafficMonthly(st Store, iface string, months, startDay int, now time.Time) ([]TrafficTotal, error) {
+	if months <= 0 {
+		months = 12
+	}
+	cur := CycleStart(now, startDay)
+	first := cur.AddDate(0, -(months - 1), 0)
+	rows, err := st.TrafficDays(first.Format(dayLayout), now.Format(dayLayout))
+	if err != nil {
+		return nil, err
+	}
+	var out []TrafficTotal
+	for c := first; !c.After(cur); c = c.AddDate(0, 1, 0) {
+		t := SumTraffic(rows, iface, c.Format(dayLayout), c.AddDate(0, 1, 0).Format(dayLayout))
+		t.Period = c.Format("2006-01")
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// CurrentTrafficCycle measures the running billing cycle against the quota.
+func CurrentTrafficCycle(st Store, cfg config.TrafficConfig, now time.Time) (TrafficCycle, error) {
+	start := CycleStart(now, cfg.CycleStartDay)
+	end := start.AddDate(0, 1, 0)
+	rows, err := st.TrafficDays(start.Format(dayLayout), now.Format(dayLayout))
+	if err != nil {
+		return TrafficCycle{}, err
+	}
+	c := TrafficCycle{TrafficTotal: SumTraffic(rows, "", start.Format(dayLayout), end.Format(dayLayout))}
+	c.Period = start.Format("2006-01")
+	switch cfg.QuotaDirection {
+	case "tx":
+		c.Billed = c.Tx
+	case "rx":
+		c.Billed = c.Rx
+	default:
+		c.Billed = c.Total
+	}
+	if elapsed := now.Sub(start).Seconds(); elapsed > 0 {
+		c.Projected = uint64(float64(c.Billed) / elapsed * end.Sub(start).Seconds())
+	}
+	if cfg.QuotaGB > 0 {
+		c.QuotaBytes = uint64(cfg.QuotaGB * (1 << 30))
+		c.UsedPercent = float64(c.Billed) / float64(c.QuotaBytes) * 100
+	}
+	ifaces := map[string]bool{}
+	for _, r := range rows {
+		ifaces[r.Iface] = true
+	}
+	c.Interfaces = []TrafficTotal{}
+	for name := range ifaces {
+		t := SumTraffic(rows, name, c.Start, c.End)
+		t.Period = name
+		c.Interfaces = append(c.Interfaces, t)
+	}
+	sort.Slice(c.Interfaces, func(i, j int) bool { return c.Interfaces[i].Period < c.Interfaces[j].Period })
+	return c, nil
+}
+
+// StartTrafficAccounting reads the interface counters every minute, adds
+// the difference to today's buckets and alerts when the cycle's usage
+// crosses one of the configured quota percentages.
+func StartTrafficAccounting(st Store, cfg *config.Config) {
+	tc := cfg.Traffic
+	tick := func() {
+		counters, err := collector.GetInterfaceCounters(tc.Interfaces)
+		if err != nil {
+			log.Printf("traffic counters: %v", err)
+			return
+		}
+		prev, err := st.TrafficCounters()
+		if err != nil {
+			log.Printf("traffic counters: %v", err)
+			return
+		}
+		now := time.Now()
+		boot := collector.BootTime()
+		var usage []TrafficUsage
+		var next []TrafficCounter
+		for _, ic := range counters {
+			next = append(next, TrafficCounter{Iface: ic.Name, BootTime: boot, Rx: ic.BytesRecv, Tx: ic.BytesSent, UpdatedAt: now.Unix()})
+			p, ok := prev[ic.Name]
+			if !ok {
+				continue // first reading: start counting from here
+			}
+			rx, tx := trafficDelta(p, boot, ic.BytesRecv, ic.BytesSent)
+			if rx > 0 || tx > 0 {
+				usage = append(usage, TrafficUsage{Day: now.Format(dayLayout), Iface: ic.Name, Rx: rx, Tx: tx})
+			}
+		}
+		if err := st.RecordTraffic(usage, next); err != nil {
+			log.Printf("record traffic: %v", err)
+			return
+		}
+		if tc.QuotaGB <= 0 || len(tc.AlertPercent) == 0 {
+			return
+		}
+		cycle, err := CurrentTrafficCycle(st, tc, now)
+		if err != nil {
+			log.Printf("traffic cycle: %v", err)
+			return
+		}
+		alerted, err := st.TrafficAlerted(cycle.Start)
+		if err != nil {
+			log.Printf("traffic alerts: %v", err)
+			return
+		}
+		// several thresholds crossed at once (e.g. after downtime) raise a
+		// single alert for the highest one
+		crossed := -1.0
+		var fresh []float64
+		for _, pct := range tc.AlertPercent {
+			if cycle.UsedPercent < pct || alerted[pct] {
+				continue
+			}
+			fresh = append(fresh, pct)
+			if pct > crossed {
+				crossed = pct
+			}
+		}
+		if crossed < 0 {
+			return
+		}
+		if err := st.MarkTrafficAlerted(cycle.Start, fresh); err != nil {
+			log.Printf("traffic alerts: %v", err)
+			return
+		}
+		RaiseAlert(st, cfg, Alert{
+			Type:      fmt.Sprintf("流量(%g%%)", crossed),
+			Value:     cycle.UsedPercent,
+			Threshold: crossed,
+			Message: fmt.Sprintf("本周期（%s 起）已用流量 %.2f GB / %g GB（%.1f%%），预计周期末 %.2f GB",
+				cycle.Start, float64(cycle.Billed)/(1<<30), tc.QuotaGB, cycle.UsedPercent, float64(cycle.Projected)/(1<<30)),
+		})
+	}
+	tick()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		tick()
+	}
+}
+
+// ── SQLite ───────────────────────────────────────────────────────
+
+func (s *SQLite) TrafficCounters() (map[string]TrafficCounter, error) {
+	rows, err := s.db.Query(`SELECT iface,boot_time,rx,tx,updated_at FROM traffic_counters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[string]TrafficCounter{}
+	for rows.Next() {
+		var c TrafficCounter
+		if err := rows.Scan(&c.Iface, &c.BootTime, &c.Rx, &c.Tx, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result[c.Iface] = c
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) RecordTraffic(usage []TrafficUsage, counters []TrafficCounter) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if _, err := tx.Exec(`INSERT INTO traffic_daily (day,iface,rx,tx) VALUES (?,?,?,?)
+			ON CONFLICT(day,iface) DO UPDATE SET rx=rx+excluded.rx, tx=tx+excluded.tx`,
+			u.Day, u.Iface, u.Rx, u.Tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, c := range counters {
+		if _, err := tx.Exec(`INSERT INTO traffic_counters (iface,boot_time,rx,tx,updated_at) VALUES (?,?,?,?,?)
+			ON CONFLICT(iface) DO UPDATE SET boot_time=excluded.boot_time, rx=excluded.rx, tx=excluded.tx, updated_at=excluded.updated_at`,
+			c.Iface, c.BootTime, c.Rx, c.Tx, c.UpdatedAt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) TrafficDays(from, to string) ([]TrafficUsage, error) {
+	rows, err := s.db.Query(`SELECT day,iface,rx,tx FROM traffic_daily WHERE day>=? AND day<=? ORDER BY day, iface`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []TrafficUsage
+	for rows.Next() {
+		var u TrafficUsage
+		if err := rows.Scan(&u.Day, &u.Iface, &u.Rx, &u.Tx); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) TrafficAlerted(cycle string) (map[float64]bool, error) {
+	rows, err := s.db.Query(`SELECT percent FROM traffic_alerts WHERE cycle=?`, cycle)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[float64]bool{}
+	for rows.Next() {
+		var pct float64
+		if err := rows.Scan(&pct); err != nil {
+			return nil, err
+		}
+		result[pct] = true
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) MarkTrafficAlerted(cycle string, percents []float64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, pct := range percents {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO traffic_alerts (cycle,percent) VALUES (?,?)`, cycle, pct); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ── Memory ───────────────────────────────────────────────────────
+
+func (m *Memory) TrafficCounters() (map[string]TrafficCounter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := map[string]TrafficCounter{}
+	for k, v := range m.trafficCounters {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (m *Memory) RecordTraffic(usage []TrafficUsage, counters []TrafficCounter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range usage {
+		key := [2]string{u.Day, u.Iface}
+		if d, ok := m.trafficDays[key]; ok {
+			d.Rx += u.Rx
+			d.Tx += u.Tx
+		} else {
+			u := u
+			m.trafficDays[key] = &u
+		}
+	}
+	for _, c := range counters {
+		m.trafficCounters[c.Iface] = c
+	}
+	return nil
+}
+
+func (m *Memory) TrafficDays(from, to string) ([]TrafficUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []TrafficUsage
+	for _, d := range m.trafficDays {
+		if d.Day >= from && d.Day <= to {
+			result = append(result, *d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+		return result[i].Iface < result[j].Iface
+	})
+	return result, nil
+}
+
+func (m *Memory) TrafficAlerted(cycle string) (map[float64]bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := map[float64]bool{}
+	for pct := range m.trafficAlerts[cycle] {
+		result[pct] = true
+	}
+	return result, nil
+}
+
+func (m *Memory) MarkTrafficAlerted(cycle string, percents []float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.trafficAlerts[cycle] == nil {
+		m.trafficAlerts[cycle] = map[float64]bool{}
+	}
+	for _, pct := range percents {
+		m.trafficAlerts[cycle][pct] = true
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopanel/gopanel/internal/collector"
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+const (
+	// restartBackoff is the minimum gap between two restart attempts of
+	// the same watch, so a daemon that dies on start is not hammered.
+	restartBackoff = time.Minute
+	restartTimeout = time.Minute
+	maxRestartLog  = 2048
+)
+
+// WatchStatus is a watch together with the result of its last check.
+type WatchStatus struct {
+	config.ProcessWatch
+	Healthy       bool    `json:"healthy"`
+	Count         int     `json:"count"`
+	PIDs          []int32 `json:"pids"`
+	LastCheck     int64   `json:"last_check"`
+	MissingSince  int64   `json:"missing_since,omitempty"`
+	Restarts      int     `json:"restarts"`
+	LastRestart   int64   `json:"last_restart,omitempty"`
+	RestartOutput string  `json:"restart_output,omitempty"`
+	RestartError  string  `json:"restart_error,omitempty"`
+	Restarting    bool    `json:"restarting,omitempty"`
+}
+
+// Watchdog checks that every configured process is running with at least
+// its minimum count, alerting and optionally restarting it when it is not.
+// The watch list lives in cfg.Watchdog.Watches and is only modified
+// through Put and Delete, which hold the watchdog's lock and modify it
+// through cfg.Update so a concurrent config save never sees it half done.
+type Watchdog struct {
+	st  Store
+	cfg *config.Config
+
+	mu     sync.Mutex
+	status map[string]*WatchStatus
+}
+
+func NewWatchdog(st Store, cfg *config.Config) *Watchdog {
+	return &Watchdog{st: st, cfg: cfg, status: map[string]*WatchStatus{}}
+}
+
+// ErrCommandNotAllowed is returned by Put for a restart command that is
+// not already in the config file while watchdog.allow_api_commands is off.
+var ErrCommandNotAllowed = errors.New("restart_command can only be set in the config file (watchdog.allow_api_commands is off)")
+
+// ValidateWatch normalises w and checks its pattern.
+func ValidateWatch(w *config.ProcessWatch) error {
+	if w.Name == "" || w.Match == "" {
+		return fmt.Errorf("name and match are required")
+	}
+	if _, err := regexp.Compile(w.Match); err != nil {
+		return fmt.Errorf("invalid match pattern: %v", err)
+	}
+	if w.MinCount <= 0 {
+		w.MinCount = 1
+	}
+	return nil
+}
+
+// Put adds w or replaces the watch with the same name. Unless the config
+// allows it, w may only keep the restart command the watch already has.
+func (wd *Watchdog) Put(w config.ProcessWatch) error {
+	if err := ValidateWatch(&w); err != nil {
+		return err
+	}
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	if w.RestartCommand != "" && !wd.cfg.Watchdog.AllowAPICommands {
+		allowed := false
+		for _, cur := range wd.cfg.Watchdog.Watches {
+			if cur.Name == w.Name && cur.RestartCommand == w.RestartCommand {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrCommandNotAllowed
+		}
+	}
+	delete(wd.status, w.Name) // pattern may have changed
+	return wd.cfg.Update("", func(c *config.Config) {
+		for i := range c.Watchdog.Watches {
+			if c.Watchdog.Watches[i].Name == w.Name {
+				c.Watchdog.Watches[i] = w
+				return
+			}
+		}
+		c.Watchdog.Watches = append(c.Watchdog.Watches, w)
+	})
+}
+
+// Delete removes the named watch and reports whether it existed.
+func (wd *Watchdog) Delete(name string) bool {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	found := false
+	wd.cfg.Update("", func(c *config.Config) {
+		watches := c.Watchdog.Watches
+		for i := range watches {
+			if watches[i].Name == name {
+				c.Watchdog.Watches = append(watches[:i:i], watches[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	delete(wd.status, name)
+	return found
+}
+
+// Save writes the whole config, including the watch list, to path.
+func (wd *Watchdog) Save(path string) error {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	return wd.cfg.Update(path, nil)
+}
+
+// Status returns every watch with its latest check result.
+func (wd *Watchdog) Status() []WatchStatus {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	out := []WatchStatus{}
+	for _, w := range wd.cfg.Watchdog.Watches {
+		s := WatchStatus{ProcessWatch: w, PIDs: []int32{}}
+		if cur, ok := wd.status[w.Name]; ok {
+			s = *cur
+			s.ProcessWatch = w
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Start checks the watch list every cfg.Watchdog.Interval.
+func (wd *Watchdog) Start() {
+	interval := wd.cfg.Watchdog.Interval
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			wd.Check()
+		}
+	}()
+}
+
+// Check runs one round over all watches.
+func (wd *Watchdog) Check() {
+	wd.mu.Lock()
+	watches := append([]config.ProcessWatch(nil), wd.cfg.Watchdog.Watches...)
+	wd.mu.Unlock()
+	if len(watches) == 0 {
+		return
+	}
+	procs, err := collector.GetProcesses("pid", "asc", 0)
+	if err != nil {
+		log.Printf("watchdog: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, w := range watches {
+		re, err := regexp.Compile(w.Match)
+		if err != nil {
+			continue
+		}
+		pids := []int32{}
+		for _, p := range procs {
+			if re.MatchString(p.Name) || re.MatchString(p.Cmdline) {
+				pids = append(pids, p.PID)
+			}
+		}
+		wd.update(w, pids, now)
+	}
+}
+
+func (wd *Watchdog) update(w config.ProcessWatch, pids []int32, now time.Time) {
+	minCount := w.MinCount
+	if minCount <= 0 {
+		minCount = 1
+	}
+	wd.mu.Lock()
+	s, ok := wd.status[w.Name]
+	if !ok {
+		s = &WatchStatus{}
+		wd.status[w.Name] = s
+	}
+	s.ProcessWatch = w
+	s.Count, s.PIDs, s.LastCheck = len(pids), pids, now.Unix()
+	s.Healthy = len(pids) >= minCount
+	if s.Healthy {
+		if s.MissingSince != 0 {
+			log.Printf("watchdog: %s recovered (%d running)", w.Name, len(pids))
+		}
+		s.MissingSince = 0
+		wd.mu.Unlock()
+		return
+	}
+	if s.MissingSince == 0 {
+		s.MissingSince = now.Unix()
+	}
+	// a restart still running from an earlier round is not started again
+	restart := w.RestartCommand != "" && !s.Restarting && now.Sub(time.Unix(s.LastRestart, 0)) >= restartBackoff
+	if restart {
+		s.Restarts++
+		s.LastRestart = now.Unix()
+		s.Restarting = true
+	}
+	wd.mu.Unlock()
+
+	msg := fmt.Sprintf("进程 %s 运行数量 %d，低于期望 %d（匹配 %q）", w.Name, len(pids), minCount, w.Match)
+	if restart {
+		msg += "，正在执行重启命令"
+		// restarts run on their own so a slow or hung command does not
+		// hold up the checks of every other watch
+		go wd.restart(w, s)
+	}
+	RaiseAlert(wd.st, wd.cfg, Alert{
+		Type:      "进程缺失(" + w.Name + ")",
+		Value:     float64(len(pids)),
+		Threshold: float64(minCount),
+		Message:   msg,
+	})
+}
+
+// restart runs the restart command of w and records the result in s.
+func (wd *Watchdog) restart(w config.ProcessWatch, s *WatchStatus) {
+	out, err := runRestart(w.RestartCommand)
+	wd.mu.Lock()
+	s.RestartOutput, s.RestartError, s.Restarting = out, "", false
+	if err != nil {
+		s.RestartError = err.Error()
+	}
+	wd.mu.Unlock()
+	if err != nil {
+		log.Printf("watchdog: restart %s: %v", w.Name, err)
+		RaiseAlert(wd.st, wd.cfg, Alert{
+			Type:    "重启失败(" + w.Name + ")",
+			Message: fmt.Sprintf("进程 %s 的重启命令失败：%v", w.Name, err),
+		})
+	}
+}
+
+// runRestart runs command through the shell. Restart scripts usually leave
+// a daemon behind that inherits stdout, so once the shell itself exits we
+// only wait briefly for the pipe instead of until the daemon dies.
+func runRestart(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), restartTimeout)
+	defer cancel()
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout, cmd.Stderr = &buf, &buf
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	out := buf.Bytes()
+	if len(out) > maxRestartLog {
+		out = out[len(out)-maxRestartLog:]
+	}
+	return string(out), err
+}
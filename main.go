@@ -13,7 +13,9 @@ import (
 
 	"github.com/gopanel/gopanel/internal/api"
 	"github.com/gopanel/gopanel/internal/cache"
+	"github.com/gopanel/gopanel/internal/collector"
 	"github.com/gopanel/gopanel/internal/config"
+	"github.com/gopanel/gopanel/internal/sink"
 	"github.com/gopanel/gopanel/internal/store"
 	"github.com/gopanel/gopanel/internal/websocket"
 )
@@ -24,6 +26,10 @@ var webFS embed.FS
 var version = "dev"
 
 func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	cfgPath := flag.String("config", "config.yaml", "config file path")
 	flag.Parse()
 
@@ -35,21 +41,78 @@ func main() {
 
 	api.SetConfigPath(*cfgPath)
 
-	db, err := store.Init(cfg.DBPath)
+	st, err := store.Open(cfg)
 	if err != nil {
-		log.Fatalf("db init: %v", err)
+		log.Fatalf("store init: %v", err)
 	}
-	defer db.Close()
+	defer st.Close()
 
 	hub := websocket.NewHub()
 	go hub.Run()
-	go store.StartCollector(db, hub, cfg.CollectInterval)
+	sinks := sink.NewManager(cfg.Sinks)
+	sinks.Start()
+	go store.StartCollector(st, hub, sinks, cfg.CollectInterval)
+	go store.StartMaintenance(st, cfg.Storage.Retention)
+
+	// the baseline cannot reach further back than the stored history
+	if a := &cfg.Alert.Anomaly; a.Window > cfg.Storage.Retention && cfg.Storage.Retention > 0 {
+		if a.Enabled {
+			log.Printf("anomaly: window %s exceeds storage retention, using %s", a.Window, cfg.Storage.Retention)
+		}
+		a.Window = cfg.Storage.Retention
+	}
+	det := store.NewDetector(st, cfg.Alert.Anomaly)
+	det.Start()
+	if cfg.Alert.Anomaly.Enabled {
+		go store.StartAnomalyChecker(st, cfg, det)
+	}
+
+	wd := store.NewWatchdog(st, cfg)
+	wd.Start()
+	if cfg.Alert.Storage.Enabled {
+		go store.StartStorageWatch(st, cfg)
+	}
+	if cfg.Alert.Listeners.Enabled {
+		go store.StartListenerWatch(st, cfg)
+	}
+
+	cache.OnDockerRefresh(func(cs []collector.Container) {
+		if err := st.SaveContainerStats(time.Now().Unix(), cs); err != nil {
+			log.Printf("save container stats: %v", err)
+		}
+	})
+
+	if cfg.History.Services.Enabled {
+		cache.OnServicesRefresh(func(svcs []collector.SystemdService) {
+			svcs = store.SelectServices(svcs, cfg.History.Services.Units)
+			if err := st.SaveServiceStats(time.Now().Unix(), svcs); err != nil {
+				log.Printf("save service stats: %v", err)
+			}
+		})
+	}
+
+	if cfg.History.Processes.Enabled {
+		go store.StartProcessRecorder(st, cfg.History.Processes)
+	}
+	if cfg.History.Connections.Enabled {
+		go store.StartConnectionRecorder(st, cfg.History.Connections)
+	}
+	if cfg.History.DiskIO.Enabled {
+		go store.StartDiskIORecorder(st, cfg.History.DiskIO)
+	}
+
+	if cfg.Traffic.Enabled {
+		go store.StartTrafficAccounting(st, cfg)
+	}
+	if cfg.KernelEvents.Enabled {
+		go store.StartKernelEvents(st, cfg, hub)
+	}
 
 	// 启动服务端缓存，每30秒后台刷新 docker 和 services 数据
 	cache.Start(30 * time.Second)
 	api.AppVersion = version
 
-	router := api.SetupRouter(cfg, db, hub, webFS)
+	router := api.SetupRouter(cfg, st, det, wd, store.NewDirScanner(hub), hub, webFS)
 
 	srv := &http.Server{
 		Addr:         cfg.Listen,